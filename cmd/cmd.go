@@ -28,6 +28,18 @@ func BuildCmds() []cli.Command {
 			Aliases:     []string{"r"},
 			Subcommands: buildRepositoryCmds(cfg, manifest),
 		},
+		{
+			Name:        "workspace",
+			Usage:       "Commands on the repositories of the current directory.",
+			Aliases:     []string{"ws"},
+			Subcommands: buildWorkspaceCmds(cfg),
+		},
+		{
+			Name:        "manifest",
+			Usage:       "Manifest (.bench.yml) related commands.",
+			Aliases:     []string{"m"},
+			Subcommands: buildManifestCmds(cfg),
+		},
 		{
 			Name:        "github",
 			Usage:       "GitHub related commands.",
@@ -46,6 +58,12 @@ func BuildCmds() []cli.Command {
 			Aliases:     []string{"w"},
 			Subcommands: buildWorkflowCmds(cfg, manifest),
 		},
+		{
+			Name:        "hooks",
+			Usage:       "Git hooks delegating to nub, e.g. validating the commit messages.",
+			Aliases:     []string{"hook"},
+			Subcommands: buildHookCmds(cfg),
+		},
 		{
 			Name:        "confluence",
 			Usage:       "Confluence related commands.",
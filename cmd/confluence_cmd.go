@@ -12,6 +12,16 @@ import (
 func buildConfluenceCmds(cfg *core.Configuration) []cli.Command {
 	noOperation := "noop"
 	cql := "cql"
+	dryRun := "dry-run"
+	workspace := "workspace"
+	create := "create"
+	force := "force"
+	space := "space"
+	parent := "parent"
+	regex := "regex"
+	limit := "limit"
+	format := "format"
+	output := "output"
 	return []cli.Command{
 		{
 			Name:    "open",
@@ -27,35 +37,101 @@ func buildConfluenceCmds(cfg *core.Configuration) []cli.Command {
 			Aliases: []string{"s"},
 			Flags: []cli.Flag{
 				cli.BoolFlag{Name: cql, Usage: "Query as CQL"},
+				cli.IntFlag{Name: limit, Usage: "Maximum number of results. 0 returns all of them."},
+				cli.StringFlag{Name: format, Usage: "List the results as table, json or yaml instead of picking a page."},
 			},
 			Action: func(c *cli.Context) error {
 				if len(c.Args()) == 0 {
 					return errors.New("not enough args")
 				}
-				return atlassian.MustInitConfluence(cfg).SearchAndOpen(c.Bool(cql), c.Args()...)
+				opts := atlassian.SearchOptions{CQL: c.Bool(cql), Limit: c.Int(limit), Format: c.String(format)}
+				return atlassian.MustInitConfluence(cfg).SearchAndOpen(opts, c.Args()...)
 			},
 		},
 		{
-			Name:    "search-and-replace",
-			Usage:   "CQL OLD_STRING NEW_STRING",
-			Aliases: []string{"r"},
+			Name:    "publish",
+			Usage:   "Publishes the manifest, README and other markdown files to the Confluence page defined in the manifest.",
+			Aliases: []string{"p"},
 			Flags: []cli.Flag{
-				cli.BoolFlag{Name: noOperation, Usage: "No Op."},
+				cli.BoolFlag{Name: dryRun, Usage: "Print the rendered page and the diff with the current page instead of updating it."},
+				cli.BoolFlag{Name: workspace, Usage: "Publish every repository in the current directory."},
+				cli.BoolFlag{Name: create, Usage: "Create the page if the manifest does not define one and save its id in the manifest."},
+				cli.BoolFlag{Name: force, Usage: "When creating the page, overwrite the existing page with the same title instead of failing."},
+				cli.StringFlag{Name: space, Usage: "Space (key) in which the page is created. Defaults to 'confluence.space' in the config."},
+				cli.StringFlag{Name: parent, Usage: "Id of the parent page of the created page. Defaults to the manifest or the config."},
+			},
+			Action: func(c *cli.Context) error {
+				opts := atlassian.PublishOptions{
+					DryRun:       c.Bool(dryRun),
+					Create:       c.Bool(create),
+					Force:        c.Bool(force),
+					Space:        c.String(space),
+					ParentPageId: c.String(parent),
+				}
+				if c.Bool(workspace) {
+					return atlassian.MustInitConfluence(cfg).PublishWorkspaceDocumentation(opts)
+				}
+				return atlassian.MustInitConfluence(cfg).PublishDocumentation("", opts)
+			},
+		},
+		{
+			Name:      "search-and-replace",
+			Usage:     "Replaces a string in all the pages matching the query. The pages are saved locally before being updated.",
+			ArgsUsage: "CQL OLD_STRING NEW_STRING",
+			Aliases:   []string{"r"},
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: noOperation, Usage: "No Op. Shows the diff of every page instead."},
+				cli.BoolFlag{Name: regex, Usage: "OLD_STRING is a regular expression, NEW_STRING can reference its groups, e.g. $1."},
+				cli.IntFlag{Name: limit, Usage: "Maximum number of pages to update, the pages without match are not counted. 0 updates all of them."},
 			},
 			Action: func(c *cli.Context) error {
 				if len(c.Args()) != 3 {
 					return errors.New("not enough args")
 				}
-				if !utils.AskForConfirmation("This may modify a lot of pages, are you sure?") {
+				if !c.Bool(noOperation) && !utils.AskForConfirmation("This may modify a lot of pages, are you sure?") {
 					os.Exit(1)
 				}
 				return atlassian.MustInitConfluence(cfg).SearchAndReplace(
 					c.Args().Get(0),
 					c.Args().Get(1),
 					c.Args().Get(2),
-					c.Bool(noOperation),
+					atlassian.ReplaceOptions{Noop: c.Bool(noOperation), Regex: c.Bool(regex), Limit: c.Int(limit)},
 				)
 			},
 		},
+		{
+			Name:      "export",
+			Usage:     "Exports the page, or the pages matching the query, to markdown files mirroring the page tree.",
+			ArgsUsage: "PAGE_ID|CQL",
+			Aliases:   []string{"e"},
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: cql, Usage: "Query as CQL instead of a page ID."},
+				cli.IntFlag{Name: limit, Usage: "Maximum number of pages to export. 0 exports all of them."},
+				cli.StringFlag{Name: output, Value: ".", Usage: "Directory in which the pages are written."},
+				cli.BoolFlag{Name: noOperation, Usage: "No Op. Only lists the files that would be written."},
+			},
+			Action: func(c *cli.Context) error {
+				if len(c.Args()) != 1 {
+					return errors.New("expects a page ID or a CQL query")
+				}
+				return atlassian.MustInitConfluence(cfg).ExportPages(c.Args().First(), atlassian.ExportOptions{
+					CQL:       c.Bool(cql),
+					Limit:     c.Int(limit),
+					OutputDir: c.String(output),
+					Noop:      c.Bool(noOperation),
+				})
+			},
+		},
+		{
+			Name:      "restore",
+			Usage:     "Restores the pages saved before a search and replace. Pick the backup if none is passed.",
+			ArgsUsage: "[BACKUP_DIR|BACKUP_FILE]",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: noOperation, Usage: "No Op. Shows the diff of every page instead."},
+			},
+			Action: func(c *cli.Context) error {
+				return atlassian.MustInitConfluence(cfg).RestorePages(c.Args().First(), c.Bool(noOperation))
+			},
+		},
 	}
 }
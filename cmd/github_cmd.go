@@ -83,7 +83,7 @@ func buildGitHubCmds(cfg *core.Configuration, manifest *core.Manifest) []cli.Com
 			Name:  "list-reviewers",
 			Usage: "List reviewer based on the current changes.",
 			Action: func(c *cli.Context) error {
-				reviewers, err := github.MustInitGitHub(cfg).ListReviewers()
+				reviewers, err := github.MustInitGitHub(cfg).ListReviewers(core.MustInitGit(""))
 				if err != nil {
 					return err
 				}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/j-martin/nub/core"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+func buildHookCmds(cfg *core.Configuration) []cli.Command {
+	return []cli.Command{
+		{
+			Name:  "install",
+			Usage: "Install the commit-msg, prepare-commit-msg and pre-push hooks in the current repository.",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "force", Usage: "Replace the existing hooks, they are backed up with the .bak extension."},
+			},
+			Action: func(c *cli.Context) error {
+				return core.MustInitGit("").InstallHooks(c.Bool("force"))
+			},
+		},
+		{
+			Name:      "commit-msg",
+			Usage:     "Validate that the commit message follows the conventional commits format.",
+			ArgsUsage: "FILE",
+			Action: func(c *cli.Context) error {
+				if !c.Args().Present() {
+					return errors.New("the path of the commit message file is required")
+				}
+				data, err := ioutil.ReadFile(c.Args().First())
+				if err != nil {
+					return err
+				}
+				if err := core.ValidateCommitMessage(string(data)); err != nil {
+					return cli.NewExitError("Invalid commit message: "+err.Error(), 1)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "prepare-commit-msg",
+			Usage:     "Pre-fill the commit message with the type and issue key of the branch.",
+			ArgsUsage: "FILE [SOURCE [SHA]]",
+			Action: func(c *cli.Context) error {
+				if !c.Args().Present() {
+					return errors.New("the path of the commit message file is required")
+				}
+				return core.MustInitGit("").PrepareCommitMessage(c.Args().First(), c.Args().Get(1))
+			},
+		},
+		{
+			Name:      "pre-push",
+			Usage:     "Block the pushes to the default branch, and of the branches without issue key when JIRA is enabled.",
+			ArgsUsage: "REMOTE URL",
+			Action: func(c *cli.Context) error {
+				if err := core.MustInitGit("").CheckPush(os.Stdin, cfg.JIRA.Enabled); err != nil {
+					return cli.NewExitError("Push refused: "+err.Error(), 1)
+				}
+				return nil
+			},
+		},
+	}
+}
@@ -1,15 +1,50 @@
 package cmd
 
 import (
+	"fmt"
+	"log"
+	"strings"
+
 	"github.com/j-martin/nub/core"
 	"github.com/j-martin/nub/integrations/github"
+	"github.com/j-martin/nub/utils"
+	"github.com/pkg/errors"
 	"github.com/urfave/cli"
 	"gopkg.in/yaml.v2"
-	"log"
-	"os"
 )
 
+const (
+	manifestTypeFlag     = "type"
+	manifestLanguageFlag = "language"
+	manifestPlatformFlag = "platform"
+	manifestActiveFlag   = "active"
+	manifestInactiveFlag = "inactive"
+)
+
+func buildManifestFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringSliceFlag{Name: manifestTypeFlag + ", only-with-manifest-type", Usage: "Filter by type, e.g. service. Can be repeated."},
+		cli.StringSliceFlag{Name: manifestLanguageFlag, Usage: "Filter by language, e.g. scala. Can be repeated."},
+		cli.StringSliceFlag{Name: manifestPlatformFlag, Usage: "Filter by platform. Can be repeated."},
+		cli.BoolFlag{Name: manifestActiveFlag, Usage: "Only active projects."},
+		cli.BoolFlag{Name: manifestInactiveFlag, Usage: "Only inactive projects."},
+	}
+}
+
+func getManifestFilter(c *cli.Context) core.ManifestFilter {
+	return core.ManifestFilter{
+		Types:     c.StringSlice(manifestTypeFlag),
+		Languages: c.StringSlice(manifestLanguageFlag),
+		Platforms: c.StringSlice(manifestPlatformFlag),
+		Active:    c.Bool(manifestActiveFlag),
+		Inactive:  c.Bool(manifestInactiveFlag),
+	}
+}
+
 func buildManifestCmds(cfg *core.Configuration) []cli.Command {
+	sortBy := "sort"
+	format := "format"
+	strict := "strict"
 	return []cli.Command{
 		{
 			Name:    "create",
@@ -21,23 +56,102 @@ func buildManifestCmds(cfg *core.Configuration) []cli.Command {
 			},
 		},
 		{
-			Name:    "validate",
-			Aliases: []string{"v"},
-			Usage:   "Validates the manifest.",
+			Name:      "validate",
+			Aliases:   []string{"v"},
+			Usage:     "Validates the manifest(s). Exits with a non-zero code if invalid, e.g. for pre-commit hooks.",
+			ArgsUsage: "[FILE]...",
+			Action: func(c *cli.Context) error {
+				files := c.Args()
+				if len(files) == 0 {
+					files = []string{""}
+				}
+				errorCount := 0
+				for _, f := range files {
+					errs, err := core.ValidateManifestFile(f, cfg.Manifest.Types)
+					if err != nil {
+						return err
+					}
+					for _, e := range errs {
+						fmt.Println(e.Error())
+					}
+					errorCount += len(errs)
+				}
+				if errorCount > 0 {
+					return errors.Errorf("the manifest is invalid, %v error(s) found", errorCount)
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "list",
+			Aliases: []string{"l"},
+			Usage:   "Lists the manifests of all the repositories in the current directory.",
+			Flags: append(buildManifestFilterFlags(),
+				cli.StringFlag{Name: sortBy, Value: "name", Usage: "Sort by name, repository, type, language, platform or active."},
+				cli.StringFlag{Name: format, Value: utils.TableFormat, Usage: "Output format: table, json or yaml."},
+			),
+			Action: func(c *cli.Context) error {
+				manifests, err := core.LoadManifests()
+				if err != nil {
+					return err
+				}
+				manifests = manifests.Filter(getManifestFilter(c))
+				if err := manifests.SortBy(c.String(sortBy)); err != nil {
+					return err
+				}
+				return utils.PrintStructured(c.String(format), manifests, manifests.Rows())
+			},
+		},
+		{
+			Name:    "graph",
+			Aliases: []string{"g"},
+			Usage:   "Exports the dependency graph of all the repositories in the current directory.",
+			Flags: append(buildManifestFilterFlags(),
+				cli.StringFlag{Name: format, Value: "dot", Usage: "Output format: dot (Graphviz) or mermaid."},
+				cli.BoolFlag{Name: strict, Usage: "Fails if cycles are detected."},
+			),
+			Action: func(c *cli.Context) error {
+				manifests, err := core.LoadManifests()
+				if err != nil {
+					return err
+				}
+				graph := core.BuildDependencyGraph(manifests.Filter(getManifestFilter(c)))
+				switch c.String(format) {
+				case "dot":
+					fmt.Print(graph.DOT())
+				case "mermaid":
+					fmt.Print(graph.Mermaid())
+				default:
+					return errors.Errorf("unknown format '%v', must be dot or mermaid", c.String(format))
+				}
+				cycles := graph.Cycles()
+				for _, cycle := range cycles {
+					log.Printf("Cycle detected: %v", strings.Join(cycle, " -> "))
+				}
+				if c.Bool(strict) && len(cycles) > 0 {
+					return errors.Errorf("%v cycle(s) detected", len(cycles))
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "show",
+			Aliases: []string{"s"},
+			Usage:   "Shows the manifest with the code owners populated.",
 			Action: func(c *cli.Context) error {
-				//TODO: Build proper validation
 				manifest, err := core.LoadManifest()
 				if err != nil {
-					log.Fatal(err)
-					os.Exit(1)
+					return err
 				}
-				manifest.Version = c.String("artifact-version")
-				err = github.MustInitGitHub(cfg).PopulateOwners(manifest)
+				err = github.MustInitGitHub(cfg).PopulateOwners(core.MustInitGit(""), manifest)
 				if err != nil {
 					log.Print(err)
 				}
-				yml, _ := yaml.Marshal(manifest)
-				log.Println(string(yml))
+				yml, err := yaml.Marshal(manifest)
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(yml))
 				return nil
 			},
 		},
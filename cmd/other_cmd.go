@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"log"
 
 	"github.com/j-martin/nub/core"
@@ -64,5 +65,59 @@ func buildRepositoryCmds(cfg *core.Configuration, manifest *core.Manifest) []cli
 				return nil
 			},
 		},
+		{
+			Name:      "changelog",
+			Usage:     "Add the changes between FROM and TO to the changelog, grouped in the Keep a Changelog sections: feat is Added, fix is Fixed, the breaking removals are Removed, the deprecations are Deprecated, the security scope is Security and the rest is Changed.",
+			ArgsUsage: "FROM [TO]",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "version", Usage: "Version of the section. Required unless TO is a tag."},
+				cli.StringFlag{Name: "file", Value: "CHANGELOG.md", Usage: "Changelog to update."},
+				cli.BoolFlag{Name: "no-jira", Usage: "Do not fetch the summary of the JIRA issues."},
+				cli.BoolFlag{Name: "no-github", Usage: "Do not fetch the title and author of the PRs."},
+				cli.BoolFlag{Name: "noop", Usage: "Print the section instead of updating the changelog."},
+			},
+			Action: func(c *cli.Context) error {
+				if !c.Args().Present() {
+					return errors.New("the FROM ref is required, e.g. the previous version tag")
+				}
+				to := "HEAD"
+				if len(c.Args()) > 1 {
+					to = c.Args().Get(1)
+				}
+				wf := &Workflow{cfg: cfg, manifest: manifest}
+				return wf.Changelog(ChangelogOptions{
+					From:     c.Args().First(),
+					To:       to,
+					Version:  c.String("version"),
+					File:     c.String("file"),
+					NoJIRA:   c.Bool("no-jira"),
+					NoGitHub: c.Bool("no-github"),
+					Noop:     c.Bool("noop"),
+				})
+			},
+		},
+		{
+			Name:  "prune-branches",
+			Usage: "Delete the local branches that were merged, including squash merged PRs, or whose upstream is gone.",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "remote", Usage: "Also delete the branches on the remote."},
+				cli.BoolFlag{Name: "workspace", Usage: "Prune the branches of every repository in the current directory."},
+				cli.BoolFlag{Name: "yes, y", Usage: "Delete the branches without picking them."},
+				cli.BoolFlag{Name: "no-github", Usage: "Do not look for the merged PRs on GitHub."},
+				cli.BoolFlag{Name: "force", Usage: "Delete the branches whose upstream is gone even if git does not see them as merged."},
+				cli.BoolFlag{Name: "noop", Usage: "List the branches without deleting them."},
+			},
+			Action: func(c *cli.Context) error {
+				wf := &Workflow{cfg: cfg, manifest: manifest}
+				return wf.PruneBranches(PruneOptions{
+					Remote:    c.Bool("remote"),
+					Workspace: c.Bool("workspace"),
+					Yes:       c.Bool("yes"),
+					NoGitHub:  c.Bool("no-github"),
+					Force:     c.Bool("force"),
+					Noop:      c.Bool("noop"),
+				})
+			},
+		},
 	}
 }
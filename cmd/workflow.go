@@ -1,8 +1,15 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/j-martin/nub/core"
 	"github.com/j-martin/nub/integrations/atlassian"
@@ -49,60 +56,696 @@ func (wf *Workflow) JIRA() *atlassian.JIRA {
 	return wf.jira
 }
 
-func (wf *Workflow) MassUpdate(unstash bool) error {
-	return core.ForEachRepo(func(repoDir string) (string, error) {
-		return core.MustInitGit(repoDir).Sync(unstash)
+type SyncOptions struct {
+	// only fast-forward and restore the original branch, nothing is lost. See core.Git.SafeSync.
+	Safe bool
+	// in safe mode, stash the changes of the dirty trees and restore them at the end.
+	Stash bool
+	// otherwise, apply the changes stashed by the reset at the end.
+	Unstash bool
+}
+
+func (o SyncOptions) sync(g *core.Git) (string, error) {
+	if o.Safe {
+		return g.SafeSync(o.Stash)
+	}
+	return g.Sync(o.Unstash)
+}
+
+func (wf *Workflow) MassUpdate(opts core.RepositoryOperationOptions, sync SyncOptions) error {
+	return core.ForEachRepo(opts, func(ctx context.Context, repoDir string) (string, error) {
+		return sync.sync(core.MustInitGitWithContext(ctx, repoDir))
 	})
 }
 
-func (wf *Workflow) MassStart(unstash bool) error {
+func (wf *Workflow) MassStart(opts core.RepositoryOperationOptions, sync SyncOptions) error {
 	issue, err := wf.JIRA().PickAssignedIssue()
 	if err != nil {
 		return err
 	}
 
-	return core.ForEachRepo(func(repo string) (string, error) {
-		g := core.MustInitGit(repo)
-		output, err := g.Sync(unstash)
+	return core.ForEachRepo(opts, func(ctx context.Context, repo string) (string, error) {
+		g := core.MustInitGitWithContext(ctx, repo)
+		output, err := sync.sync(g)
 		if err != nil {
 			return output, err
 		}
+		if sync.Safe {
+			// the existing branch is not overwritten.
+			return "", g.CreateBranch(wf.JIRA().IssueBranchName(issue))
+		}
 		return "", wf.JIRA().CreateBranchFromIssue(issue, repo, true)
 	})
 }
 
-func (wf *Workflow) MassDiff() error {
-	return core.ForEachRepo(func(repo string) (string, error) {
-		g := core.MustInitGit(repo)
+func (wf *Workflow) MassDiff(opts core.RepositoryOperationOptions) error {
+	return core.ForEachRepo(opts, func(ctx context.Context, repo string) (string, error) {
+		g := core.MustInitGitWithContext(ctx, repo)
 		return g.Diff()
 	})
 }
 
-func (wf *Workflow) MassDone(noOperation bool) error {
-	return core.ForEachRepo(func(repoDir string) (string, error) {
-		g := core.MustInitGit(repoDir)
-		if g.ContainedUncommittedChanges() {
-			utils.ConditionalOp(fmt.Sprintf("%v - Committing.", repoDir), noOperation, func() error {
-				return g.CommitWithBranchName()
-			})
+func (wf *Workflow) MassDone(opts core.RepositoryOperationOptions, noOperation bool) error {
+	return core.ForEachRepo(opts, func(ctx context.Context, repoDir string) (string, error) {
+		return wf.done(ctx, repoDir, noOperation)
+	})
+}
+
+// done commits the changes of the repository, pushes the branch and creates the PR.
+func (wf *Workflow) done(ctx context.Context, repoDir string, noOperation bool) (string, error) {
+	g := core.MustInitGitWithContext(ctx, repoDir)
+	if g.ContainedUncommittedChanges() {
+		err := utils.ConditionalOp(fmt.Sprintf("%v - Committing.", repoDir), noOperation, func() error {
+			return g.CommitWithBranchName()
+		})
+		if err != nil {
+			return "", err
 		}
+	}
 
-		if !g.IsDifferentFromMaster() {
-			log.Printf("%v - No commits. Skipping.", repoDir)
-			return "", nil
+	if !g.IsDifferentFromMaster() {
+		log.Printf("%v - No commits. Skipping.", repoDir)
+		return "", nil
+	}
+
+	err := utils.ConditionalOp(fmt.Sprintf("%v - Pushing", repoDir), noOperation, func() error {
+		pr, err := wf.GitHub().CreatePullRequest("", "", repoDir)
+		if err != nil {
+			return err
 		}
+		log.Printf("%v - PR: %v", repoDir, pr.GetHTMLURL())
+		return core.RecordCampaignPullRequest(g.GetCurrentBranch(), g.GetIssueKeyFromBranch(), core.CampaignPullRequest{
+			Repository: pr.GetBase().GetRepo().GetName(),
+			Owner:      pr.GetBase().GetRepo().GetOwner().GetLogin(),
+			Number:     pr.GetNumber(),
+			URL:        pr.GetHTMLURL(),
+		})
+	})
+	return "", err
+}
 
-		utils.ConditionalOp(fmt.Sprintf("%v - Pushing", repoDir), noOperation, func() error {
-			err := g.Push(wf.cfg)
-			if err != nil {
+// pickCampaign loads the campaign of the branch, or the one picked if the branch is empty.
+func pickCampaign(branch string) (*core.Campaign, error) {
+	if branch == "" {
+		campaigns, err := core.ListCampaigns()
+		if err != nil {
+			return nil, err
+		}
+		if len(campaigns) == 0 {
+			return nil, errors.New("no campaign found, campaigns are created by 'mass done' and 'mass apply'")
+		}
+		branch, err = utils.PickItem("Pick a campaign", campaigns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return core.LoadCampaign(branch)
+}
+
+func (wf *Workflow) MassStatus(branch, format string) error {
+	campaign, err := pickCampaign(branch)
+	if err != nil {
+		return err
+	}
+	statuses, err := wf.GitHub().CampaignStatus(campaign)
+	if err != nil {
+		return err
+	}
+	return utils.PrintStructured(format, statuses, github.CampaignRows(statuses))
+}
+
+func (wf *Workflow) MassMerge(branch string, noOperation bool) error {
+	campaign, err := pickCampaign(branch)
+	if err != nil {
+		return err
+	}
+	return wf.GitHub().MergeCampaign(campaign, noOperation)
+}
+
+func (wf *Workflow) MassClose(branch string, noOperation bool) error {
+	campaign, err := pickCampaign(branch)
+	if err != nil {
+		return err
+	}
+	if err := wf.GitHub().CloseCampaign(campaign, noOperation); err != nil {
+		return err
+	}
+	return deleteCampaignLocalBranches(campaign, noOperation)
+}
+
+// deleteCampaignLocalBranches deletes the branch of the campaign in the repositories of the current directory. The
+// failures are logged and the other repositories are still processed.
+func deleteCampaignLocalBranches(campaign *core.Campaign, noOperation bool) error {
+	failures := 0
+	for _, p := range campaign.PullRequests {
+		if !utils.IsRepository(p.Repository) {
+			continue
+		}
+		g := core.MustInitGit(p.Repository)
+		if !g.BranchExists(campaign.Branch) {
+			continue
+		}
+		err := utils.ConditionalOp(fmt.Sprintf("%v - Deleting the local branch %v", p.Repository, campaign.Branch), noOperation, func() error {
+			if g.GetCurrentBranch() == campaign.Branch {
+				if err := g.RunGit("checkout", g.GetDefaultBranch()); err != nil {
+					return err
+				}
+			}
+			return g.DeleteBranch(campaign.Branch)
+		})
+		if err != nil {
+			log.Printf("%v - Failed to delete the local branch %v: %v", p.Repository, campaign.Branch, err)
+			failures++
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%v local branches failed to be deleted", failures)
+	}
+	return nil
+}
+
+type UpdateBranchOptions struct {
+	// rebase or merge, the configured strategy is used if empty.
+	Strategy string
+	// abort the rebase or merge on conflicts instead of leaving it in progress.
+	AbortOnConflict bool
+	NoPush          bool
+}
+
+// UpdateBranch updates the current branch with the default branch. The branch is pushed if it has an open PR.
+func (wf *Workflow) UpdateBranch(opts UpdateBranchOptions) error {
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = wf.cfg.Git.UpdateStrategy
+	}
+	if strategy == "" {
+		strategy = core.UpdateStrategyRebase
+	}
+	// the lease is read before UpdateBranch fetches, so that the commits pushed since are not overwritten.
+	lease := wf.Git().GetRemoteBranchHash(wf.Git().GetPushRemoteName(wf.cfg), wf.Git().GetCurrentBranch())
+	conflicts, err := wf.Git().UpdateBranch(strategy)
+	if len(conflicts) > 0 {
+		owners, ownersErr := wf.GitHub().FileOwners(wf.Git(), conflicts)
+		if ownersErr != nil {
+			log.Printf("Could not read the CODEOWNERS: %v", ownersErr)
+		}
+		rows := [][]string{{"CONFLICT", "OWNERS"}}
+		for _, f := range conflicts {
+			rows = append(rows, []string{f, strings.Join(owners[f], ", ")})
+		}
+		utils.PrintTable(rows)
+		if opts.AbortOnConflict {
+			log.Printf("Aborting the %v.", strategy)
+			if abortErr := wf.Git().AbortUpdateBranch(strategy); abortErr != nil {
+				return abortErr
+			}
+			return err
+		}
+		log.Printf("Resolve the conflicts, then run 'git %v --continue', or 'git %v --abort' to cancel.", strategy, strategy)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if opts.NoPush {
+		return nil
+	}
+	branch := wf.Git().GetCurrentBranch()
+	pr, err := wf.GitHub().FindOpenPullRequest(wf.Git(), branch)
+	if err != nil {
+		return err
+	}
+	if pr == nil {
+		log.Printf("No open PR for %v, the branch is not pushed.", branch)
+		return nil
+	}
+	log.Printf("Pushing %v, PR #%v is open.", branch, pr.GetNumber())
+	if strategy == core.UpdateStrategyMerge {
+		return wf.Git().Push(wf.cfg)
+	}
+	return wf.Git().ForcePushWithLease(wf.cfg, branch, lease)
+}
+
+type RestackOptions struct {
+	// skip the detection of the squash merged parents.
+	NoGitHub bool
+	NoPush   bool
+}
+
+// Restack rebases every branch of the current stack onto its parent. The branches whose parent was merged are moved
+// onto the next unmerged parent, or the default branch, and their PR is retargeted.
+func (wf *Workflow) Restack(opts RestackOptions) error {
+	g := wf.Git()
+	original := g.GetCurrentBranch()
+	if original == "" {
+		return errors.New("HEAD is detached, checkout a branch of the stack first")
+	}
+	if g.ContainedUncommittedChanges() {
+		return errors.New("the tree contains uncommitted changes, commit or stash them first")
+	}
+	stack, err := g.GetStack(original)
+	if err != nil {
+		return err
+	}
+	// the leases are read before fetching, so that the commits pushed since are not overwritten.
+	leases := map[string]string{}
+	for _, branch := range stack {
+		leases[branch] = g.GetRemoteBranchHash(g.GetPushRemoteName(wf.cfg), branch)
+	}
+	if err := g.RunGit("fetch", g.GetUpstreamRemoteName()); err != nil {
+		return err
+	}
+	branches, err := g.ListLocalBranches()
+	if err != nil {
+		return err
+	}
+	// the tips before restacking, the commits of a child are the ones after the previous tip of its parent.
+	tips := map[string]string{}
+	for _, b := range branches {
+		tips[b.Name] = b.Hash
+	}
+	merged, err := g.ListBranchesMergedInto(g.GetDefaultBranchRef())
+	if err != nil {
+		return err
+	}
+	mergedPRs := map[string]bool{}
+	isMerged := func(branch string) bool {
+		tip, ok := tips[branch]
+		if !ok || merged[branch] {
+			return true
+		}
+		if opts.NoGitHub {
+			return false
+		}
+		if found, checked := mergedPRs[branch]; checked {
+			return found
+		}
+		pr, err := wf.GitHub().FindMergedPullRequest(g, core.LocalBranch{Name: branch, Hash: tip})
+		if err != nil {
+			log.Printf("Could not check the PRs of %v: %v", branch, err)
+		}
+		mergedPRs[branch] = pr != nil
+		return pr != nil
+	}
+
+	var restacked []string
+	for _, branch := range stack {
+		if isMerged(branch) {
+			log.Printf("%v is merged. Skipping.", branch)
+			continue
+		}
+		oldParent := g.GetParentBranch(branch)
+		// the commits of the branch are the ones after the tip of its parent, or after the recorded base if the
+		// parent was deleted or rewritten since.
+		oldBase := tips[oldParent]
+		if !g.IsAncestor(oldBase, branch) {
+			oldBase = g.GetParentBase(branch)
+		}
+		parent := oldParent
+		visited := map[string]bool{branch: true}
+		for parent != "" && parent != g.GetDefaultBranch() && !visited[parent] && isMerged(parent) {
+			visited[parent] = true
+			parent = g.GetParentBranch(parent)
+		}
+		if visited[parent] {
+			return fmt.Errorf("the parents of %v form a cycle, fix them with 'git config branch.<name>.nubParent'", branch)
+		}
+		newBase := parent
+		if parent == "" || parent == g.GetDefaultBranch() {
+			parent, newBase = g.GetDefaultBranch(), g.GetDefaultBranchRef()
+		}
+		if oldParent != "" && parent != oldParent {
+			log.Printf("%v is merged, moving %v onto %v.", oldParent, branch, parent)
+			if err := g.SetParentBranch(branch, parent); err != nil {
+				return err
+			}
+		}
+		if err := g.RebaseOnto(newBase, oldBase, branch); err != nil {
+			if conflicts, _ := g.ListConflictedFiles(); len(conflicts) > 0 {
+				log.Printf("Conflicts: %v", strings.Join(conflicts, ", "))
+			}
+			log.Print("Resolve the conflicts, run 'git rebase --continue' and restack again.")
+			return err
+		}
+		if err := g.SetParentBase(branch, newBase); err != nil {
+			return err
+		}
+		restacked = append(restacked, branch)
+	}
+	if isMerged(original) && len(restacked) > 0 {
+		original = restacked[0]
+	}
+	if err := g.RunGit("checkout", original); err != nil {
+		return err
+	}
+	if opts.NoPush {
+		return nil
+	}
+	for _, branch := range restacked {
+		pr, err := wf.GitHub().FindOpenPullRequest(g, branch)
+		if err != nil {
+			return err
+		}
+		if pr == nil {
+			continue
+		}
+		if err := g.ForcePushWithLease(wf.cfg, branch, leases[branch]); err != nil {
+			return err
+		}
+		if base := g.GetBaseBranch(branch); pr.GetBase().GetRef() != base {
+			log.Printf("Retargeting PR #%v of %v onto %v.", pr.GetNumber(), branch, base)
+			if err := wf.GitHub().SetPullRequestBase(pr, base); err != nil {
 				return err
 			}
-			return wf.GitHub().CreatePR("", "", repoDir)
+		}
+	}
+	return nil
+}
+
+type ChangelogOptions struct {
+	From, To string
+	// title of the section, the TO ref by default if it is a tag.
+	Version  string
+	File     string
+	NoJIRA   bool
+	NoGitHub bool
+	// print the section instead of writing the file.
+	Noop bool
+}
+
+// Changelog adds a section with the changes between the refs to the changelog. The entries are enriched with the
+// summary of the JIRA issues and the title and author of the PRs.
+func (wf *Workflow) Changelog(opts ChangelogOptions) error {
+	g := wf.Git()
+	version := opts.Version
+	if version == "" {
+		if !g.TagExists(opts.To) {
+			return fmt.Errorf("'%v' is not a tag, set the version of the section with --version", opts.To)
+		}
+		version = opts.To
+	}
+	commits, err := g.LogFirstParent(opts.From, opts.To)
+	if err != nil {
+		return err
+	}
+	entries := g.ParseChangelogEntries(commits)
+	summaries := map[string]string{}
+	for i := range entries {
+		e := &entries[i]
+		if e.PullRequest != nil && !opts.NoGitHub {
+			pr, err := wf.GitHub().GetPullRequest(g, e.PullRequest.Number)
+			if err != nil {
+				log.Printf("Could not get the PR #%v: %v", e.PullRequest.Number, err)
+			} else {
+				e.SetPullRequest(pr.GetTitle(), pr.GetUser().GetLogin(), pr.GetHTMLURL())
+			}
+		}
+		if opts.NoJIRA || !wf.cfg.JIRA.Enabled {
+			continue
+		}
+		for j := range e.Issues {
+			key := e.Issues[j].Key
+			if _, ok := summaries[key]; !ok {
+				if summaries[key], err = wf.JIRA().GetIssueSummary(key); err != nil {
+					log.Printf("Could not get the issue %v: %v", key, err)
+				}
+			}
+			e.Issues[j].Summary = summaries[key]
+			e.Issues[j].URL = wf.JIRA().IssueURL(key)
+		}
+	}
+	section := core.RenderChangelogSection(version, time.Now(), entries)
+	if opts.Noop {
+		fmt.Print(section)
+		return nil
+	}
+	log.Printf("Adding %v changes to %v.", len(entries), opts.File)
+	return core.PrependChangelog(opts.File, section)
+}
+
+type PruneOptions struct {
+	// also delete the branches on the remote.
+	Remote bool
+	// prune every repository of the current directory.
+	Workspace bool
+	// delete the branches without picking them.
+	Yes bool
+	// skip the detection of the squash merged PRs.
+	NoGitHub bool
+	// force delete the branches whose upstream is gone even if they are not merged.
+	Force bool
+	Noop  bool
+}
+
+type prunableBranch struct {
+	branch core.LocalBranch
+	reason string
+	// the branch is only deleted by git if merged, unless forced.
+	safeDelete bool
+}
+
+// PruneBranches deletes the local branches that were merged or whose upstream is gone. In the workspace, the failures
+// are logged and the other repositories are still pruned.
+func (wf *Workflow) PruneBranches(opts PruneOptions) error {
+	if !opts.Workspace {
+		return wf.pruneBranches("", opts)
+	}
+	repos, err := core.ListRepositories()
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, repo := range repos {
+		log.Printf("Pruning the branches of %v.", repo)
+		if err := wf.pruneBranches(repo, opts); err != nil {
+			log.Printf("%v: failed to prune the branches: %v", repo, err)
+			failed = append(failed, repo)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to prune the branches of %v", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (wf *Workflow) pruneBranches(repoDir string, opts PruneOptions) error {
+	g := core.MustInitGit(repoDir)
+	if err := g.FetchAndPrune(); err != nil {
+		return err
+	}
+	prunable, err := wf.listPrunableBranches(g, opts)
+	if err != nil {
+		return err
+	}
+	if len(prunable) == 0 {
+		log.Print("No branch to prune.")
+		return nil
+	}
+	var items []string
+	byItem := map[string]prunableBranch{}
+	for _, p := range prunable {
+		item := fmt.Sprintf("%v (%v)", p.branch.Name, p.reason)
+		items = append(items, item)
+		byItem[item] = p
+	}
+	if !opts.Yes && !opts.Noop {
+		if items, err = utils.PickItems("Branches to delete", items); err != nil {
+			return err
+		}
+	}
+	for _, item := range items {
+		p := byItem[item]
+		err := utils.ConditionalOp(fmt.Sprintf("Deleting %v, %v", p.branch.Name, p.reason), opts.Noop, func() error {
+			if p.safeDelete && !opts.Force {
+				return g.DeleteMergedBranch(p.branch.Name)
+			}
+			return g.DeleteBranch(p.branch.Name)
 		})
-		return "", nil
+		if err != nil && p.safeDelete && !opts.Force {
+			log.Printf("%v is not merged, use --force to delete it anyway.", p.branch.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !opts.Remote || p.branch.Gone || p.branch.Upstream == "" {
+			continue
+		}
+		parts := strings.SplitN(p.branch.Upstream, "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		err = utils.ConditionalOp(fmt.Sprintf("Deleting %v on %v", parts[1], parts[0]), opts.Noop, func() error {
+			return g.DeleteRemoteBranch(parts[0], parts[1])
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listPrunableBranches skips the default and current branches, and the branches without commits yet, i.e. pointing
+// to the default branch. The squash merged branches are found via their PRs.
+func (wf *Workflow) listPrunableBranches(g *core.Git, opts PruneOptions) ([]prunableBranch, error) {
+	branches, err := g.ListLocalBranches()
+	if err != nil {
+		return nil, err
+	}
+	defaultRef := g.GetDefaultBranchRef()
+	merged, err := g.ListBranchesMergedInto(defaultRef)
+	if err != nil {
+		defaultRef = g.GetDefaultBranch()
+		if merged, err = g.ListBranchesMergedInto(defaultRef); err != nil {
+			return nil, err
+		}
+	}
+	defaultHash, err := g.RunGitWithStdout("rev-parse", defaultRef)
+	if err != nil {
+		return nil, err
+	}
+	var prunable []prunableBranch
+	for _, b := range branches {
+		if b.Name == g.GetDefaultBranch() || b.Name == g.GetCurrentBranch() || b.Hash == defaultHash {
+			continue
+		}
+		if merged[b.Name] {
+			prunable = append(prunable, prunableBranch{b, "merged", false})
+			continue
+		}
+		number := wf.findMergedPullRequest(g, b, opts)
+		switch {
+		case number != 0 && b.Gone:
+			prunable = append(prunable, prunableBranch{b, fmt.Sprintf("upstream gone, PR #%v merged", number), false})
+		case number != 0:
+			prunable = append(prunable, prunableBranch{b, fmt.Sprintf("PR #%v merged", number), false})
+		case b.Gone:
+			prunable = append(prunable, prunableBranch{b, "upstream gone", true})
+		}
+	}
+	return prunable, nil
+}
+
+// findMergedPullRequest returns the number of the merged PR of the branch, 0 if none.
+func (wf *Workflow) findMergedPullRequest(g *core.Git, b core.LocalBranch, opts PruneOptions) int {
+	if opts.NoGitHub {
+		return 0
+	}
+	pr, err := wf.GitHub().FindMergedPullRequest(g, b)
+	if err != nil {
+		log.Printf("Could not check the PRs of %v: %v", b.Name, err)
+		return 0
+	}
+	return pr.GetNumber()
+}
+
+type ApplyOptions struct {
+	Branch string
+	// shell command run in every repository.
+	Command string
+	// patch file applied to every repository.
+	Patch string
+	// commit message, inferred from the branch name if empty. It is made a conventional commit message.
+	Message string
+	// reset the existing branches with the same name instead of failing, ignored in noop mode.
+	Force bool
+	Noop  bool
+}
+
+// MassApply runs the command or applies the patch on a new branch of every repository. The repositories that changed
+// are committed, pushed and a PR is created for them. In noop mode, the diffs are shown and the branches dropped.
+func (wf *Workflow) MassApply(opts core.RepositoryOperationOptions, apply ApplyOptions) error {
+	if (apply.Command == "") == (apply.Patch == "") {
+		return errors.New("either a command or a patch must be passed")
+	}
+	if apply.Branch == "" {
+		return errors.New("a branch name is required")
+	}
+	if apply.Patch != "" {
+		patch, err := filepath.Abs(apply.Patch)
+		if err != nil {
+			return err
+		}
+		apply.Patch = patch
+	}
+	repos, err := core.ListSelectedRepositories(opts.Selection)
+	if err != nil {
+		return err
+	}
+
+	var mutex sync.Mutex
+	var changed []string
+	results := core.RunConcurrentRepositoryOperations(repos, opts, func(ctx context.Context, repoDir string) (string, error) {
+		output, hasChanged, err := wf.apply(ctx, repoDir, apply)
+		if hasChanged {
+			mutex.Lock()
+			changed = append(changed, repoDir)
+			mutex.Unlock()
+		}
+		return output, err
+	})
+	for _, r := range results {
+		if r.Output != "" {
+			fmt.Println(r.Output)
+		}
+	}
+	if opts.Report != "" {
+		if err := results.WriteReport(opts.Report); err != nil {
+			return err
+		}
+	}
+	sort.Strings(changed)
+	log.Printf("%v of %v repositories changed: %v", len(changed), len(repos), strings.Join(changed, ", "))
+	if failed := results.Failed(); len(failed) > 0 {
+		for _, r := range failed {
+			log.Printf("%v failed: %v", r.Repository, r.Err)
+		}
+		return errors.New("some repositories failed to apply the changes, no PR created")
+	}
+	if apply.Noop || len(changed) == 0 {
+		return nil
+	}
+	opts.Report = ""
+	return core.ConcurrentRepositoryOperations(changed, opts, func(ctx context.Context, repoDir string) (string, error) {
+		return wf.done(ctx, repoDir, false)
 	})
 }
 
+func (wf *Workflow) apply(ctx context.Context, repoDir string, apply ApplyOptions) (output string, changed bool, err error) {
+	g := core.MustInitGitWithContext(ctx, repoDir)
+	original := g.GetCurrentBranch()
+	// the working tree and the other branches are left as is, the existing branch is only reset if forced.
+	if g.ContainedUncommittedChanges() {
+		return "", false, errors.New("the tree contains uncommitted changes, commit or stash them first")
+	}
+	if err = g.CreateBranchFromDefault(apply.Branch, apply.Force && !apply.Noop); err != nil {
+		return "", false, err
+	}
+	if apply.Command != "" {
+		output, err = utils.RunCmdInDirWithFullOutputContext(ctx, repoDir, "sh", "-c", apply.Command)
+	} else {
+		output, err = g.RunGitWithFullOutput("apply", apply.Patch)
+	}
+	if err != nil {
+		if discardErr := g.DiscardBranch(apply.Branch, original); discardErr != nil {
+			log.Printf("%v - Failed to restore the branch '%v': %v", repoDir, original, discardErr)
+		}
+		return output, false, err
+	}
+	if !g.ContainedUncommittedChanges() {
+		log.Printf("%v - No changes.", repoDir)
+		return "", false, g.DiscardBranch(apply.Branch, original)
+	}
+	if apply.Noop {
+		diff, err := g.StagedDiff()
+		if err != nil {
+			return diff, true, err
+		}
+		return "=== " + repoDir + "\n" + diff, true, g.DiscardBranch(apply.Branch, original)
+	}
+	return "", true, g.CommitAll(wf.cfg, g.NewCommitMessage(apply.Message).String())
+}
+
 func (wf *Workflow) CreatePR(title, body string, review bool) error {
 	if wf.JIRA().IsEnabled() && (review || utils.AskForConfirmation("Transition issue?")) {
 		err := wf.JIRA().TransitionIssue("", "review")
@@ -125,12 +768,17 @@ func (wf *Workflow) OpenCommit(c *core.GitCommit) error {
 	issueKey := wf.Git().GetIssueIdRegex().FindString(c.Subject)
 	pr := wf.Git().GetPRRegex().FindStringSubmatch(c.Subject)
 
+	defaultBranch := wf.manifest.DefaultBranch
+	if defaultBranch == "" {
+		defaultBranch = wf.Git().GetDefaultBranch()
+	}
+
 	openList := map[string]func() error{
 		"GitHub Commit": func() error {
 			return wf.GitHub().OpenCommit(wf.manifest, c)
 		},
-		"GitHub Compare with Master": func() error {
-			return wf.GitHub().OpenCompareCommitsPage(wf.manifest, c, "master")
+		"GitHub Compare with " + defaultBranch: func() error {
+			return wf.GitHub().OpenCompareCommitsPage(wf.manifest, c, defaultBranch)
 		},
 	}
 	if len(pr) > 2 && pr[2] != "" {
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"github.com/j-martin/nub/core"
 	"github.com/j-martin/nub/integrations/atlassian"
 	"github.com/j-martin/nub/integrations/github"
@@ -15,6 +16,10 @@ func buildWorkflowCmds(cfg *core.Configuration, manifest *core.Manifest) []cli.C
 	compare := "compare-only"
 	unstash := "unstash"
 	unstashDesc := "Unstash changes at the end of the update."
+	stash := "stash"
+	stashDesc := "With --safe, stash the changes of the dirty trees and restore them at the end of the update."
+	safe := "safe"
+	safeDesc := "Only fast-forward, refuse dirty trees unless --stash is set and restore the original branch. Nothing is lost."
 	return []cli.Command{
 		buildJIRAOpenBoardCmd(cfg),
 		buildJIRAClaimIssueCmd(cfg),
@@ -23,11 +28,18 @@ func buildWorkflowCmds(cfg *core.Configuration, manifest *core.Manifest) []cli.C
 		buildJIRACommentOnIssuesCmd(cfg),
 		buildJIRAListAssignedIssuesCmd(cfg),
 		{
-			Name:    "new-branch",
-			Aliases: []string{"n", "new"},
-			Usage:   "Checkout a new branch based on JIRA issues assigned to you.",
+			Name:      "new-branch",
+			Aliases:   []string{"n", "new"},
+			Usage:     "Checkout a new branch based on JIRA issues assigned to you.",
+			ArgsUsage: "[NAME]",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "stacked, s", Usage: "Create the branch on top of the current branch. Named NAME if passed, the JIRA issue is not picked."},
+			},
 			Action: func(c *cli.Context) error {
-				return atlassian.MustInitJIRA(cfg).CreateBranchFromAssignedIssue()
+				if c.Bool("stacked") && c.Args().Present() {
+					return core.MustInitGit("").CreateStackedBranch(c.Args().First())
+				}
+				return atlassian.MustInitJIRA(cfg).CreateBranchFromAssignedIssue(c.Bool("stacked"))
 			},
 		},
 		{
@@ -42,12 +54,53 @@ func buildWorkflowCmds(cfg *core.Configuration, manifest *core.Manifest) []cli.C
 			Name:    "commit",
 			Aliases: []string{"c"},
 			Usage:   "MESSAGE [OPTS]...",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "type", Usage: "Conventional commit type, inferred from the branch by default."},
+				cli.StringFlag{Name: "scope", Usage: "Scope of the change, the issue key of the branch by default."},
+				cli.StringFlag{Name: "body", Usage: "Body of the commit message."},
+				cli.StringFlag{Name: "breaking", Usage: "Describe the breaking change, adds the '!' marker and the BREAKING CHANGE footer."},
+				cli.StringSliceFlag{Name: "ref", Usage: "Issue key added as a Refs footer. Repeatable."},
+				cli.BoolFlag{Name: "interactive, i", Usage: "Prompt for every part of the message."},
+			},
 			Action: func(c *cli.Context) error {
 				message := ""
 				if len(c.Args()) > 0 {
 					message = c.Args().Get(0)
 				}
-				return core.InitGit().CommitWithIssueKey(cfg, message, c.Args().Tail())
+				return commit(cfg, c, message, c.Args().Tail())
+			},
+		},
+		{
+			Name:    "update-branch",
+			Aliases: []string{"u"},
+			Usage:   "Rebase, or merge, the current branch onto the default branch and push it if it has an open PR.",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "merge", Usage: "Merge the default branch instead of rebasing, overrides the config."},
+				cli.BoolFlag{Name: "rebase", Usage: "Rebase onto the default branch, overrides the config."},
+				cli.BoolFlag{Name: "abort-on-conflict", Usage: "Abort instead of leaving the conflicts to resolve."},
+				cli.BoolFlag{Name: "no-push", Usage: "Do not push the updated branch."},
+			},
+			Action: func(c *cli.Context) error {
+				opts := UpdateBranchOptions{AbortOnConflict: c.Bool("abort-on-conflict"), NoPush: c.Bool("no-push")}
+				if c.Bool("merge") {
+					opts.Strategy = core.UpdateStrategyMerge
+				} else if c.Bool("rebase") {
+					opts.Strategy = core.UpdateStrategyRebase
+				}
+				wf := &Workflow{cfg: cfg, manifest: manifest}
+				return wf.UpdateBranch(opts)
+			},
+		},
+		{
+			Name:  "restack",
+			Usage: "Rebase the branches of the current stack onto their parent and retarget the PRs of the merged parents.",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "no-github", Usage: "Do not look for the merged PRs on GitHub."},
+				cli.BoolFlag{Name: "no-push", Usage: "Do not push the restacked branches."},
+			},
+			Action: func(c *cli.Context) error {
+				wf := &Workflow{cfg: cfg, manifest: manifest}
+				return wf.Restack(RestackOptions{NoGitHub: c.Bool("no-github"), NoPush: c.Bool("no-push")})
 			},
 		},
 		{
@@ -92,53 +145,174 @@ func buildWorkflowCmds(cfg *core.Configuration, manifest *core.Manifest) []cli.C
 			Subcommands: []cli.Command{
 				{
 					Name:  "start",
-					Usage: "Clean the repository, checkout master, pull and create new branch.",
-					Flags: []cli.Flag{
+					Usage: "Clean the repository, checkout the default branch, pull and create new branch.",
+					Flags: append(append(buildRepositoryOperationFlags(), buildRepositorySelectionFlags()...),
 						cli.BoolFlag{Name: unstash, Usage: unstashDesc},
+						cli.BoolFlag{Name: stash, Usage: stashDesc},
+						cli.BoolFlag{Name: safe, Usage: safeDesc + " The existing branches are not overwritten."},
+					),
+					Action: func(c *cli.Context) error {
+						sync, err := getSyncOptions(c)
+						if err != nil {
+							return err
+						}
+						if !sync.Safe && !utils.AskForConfirmation("You will lose existing changes. Use --safe to keep them.") {
+							os.Exit(1)
+						}
+						return MustInitWorkflow(cfg, manifest).MassStart(getMassOperationOptions(c, cfg), sync)
 					},
+				},
+				{
+					Name:  "apply",
+					Usage: "Runs the command or applies the patch on a new branch of every repository, then commits and creates PRs for the ones that changed.",
+					Flags: append(append(buildRepositoryOperationFlags(), buildRepositorySelectionFlags()...),
+						cli.StringFlag{Name: "branch", Usage: "Name of the branch created in every repository."},
+						cli.StringFlag{Name: "command", Usage: "Shell command run in every repository."},
+						cli.StringFlag{Name: "patch", Usage: "Patch file applied to every repository."},
+						cli.StringFlag{Name: "message", Usage: "Commit message. Inferred from the branch name by default."},
+						cli.BoolFlag{Name: "force", Usage: "Reset the existing branches with the same name instead of failing."},
+						cli.BoolFlag{Name: noOperation, Usage: "Show the diffs and drop the branches instead of committing."},
+					),
 					Action: func(c *cli.Context) error {
-						if !utils.AskForConfirmation("You will lose existing changes.") {
+						if c.Bool("force") && !c.Bool(noOperation) && !utils.AskForConfirmation("The existing branches named '"+c.String("branch")+"' will be reset.") {
 							os.Exit(1)
 						}
-						return MustInitWorkflow(cfg, manifest).MassStart(c.Bool(unstash))
+						return MustInitWorkflow(cfg, manifest).MassApply(getMassOperationOptions(c, cfg), ApplyOptions{
+							Branch:  c.String("branch"),
+							Command: c.String("command"),
+							Patch:   c.String("patch"),
+							Message: c.String("message"),
+							Force:   c.Bool("force"),
+							Noop:    c.Bool(noOperation),
+						})
 					},
 				},
 				{
 					Name:    "diff",
 					Aliases: []string{"d"},
 					Usage:   "Shows the diff of all repos.",
+					Flags:   append(buildRepositoryOperationFlags(), buildRepositorySelectionFlags()...),
 					Action: func(c *cli.Context) error {
-						return MustInitWorkflow(cfg, manifest).MassDiff()
+						return MustInitWorkflow(cfg, manifest).MassDiff(getMassOperationOptions(c, cfg))
 					},
 				},
 				{
 					Name:  "done",
 					Usage: "Commit changes and create PRs. To be used after running '... start' and you made your changes.",
+					Flags: append(append(buildRepositoryOperationFlags(), buildRepositorySelectionFlags()...),
+						cli.BoolFlag{Name: noOperation, Usage: "Do not do any actions."},
+					),
+					Action: func(c *cli.Context) error {
+						if !utils.AskForConfirmation("You will create a PR for every changes made to the repo. Use `--noop` to check first. Continue?") {
+							os.Exit(1)
+						}
+						return MustInitWorkflow(cfg, manifest).MassDone(getMassOperationOptions(c, cfg), c.Bool(noOperation))
+					},
+				},
+				{
+					Name:      "status",
+					Aliases:   []string{"s"},
+					Usage:     "Shows the review, CI and merge status of the PRs of a campaign created by 'done' or 'apply'.",
+					ArgsUsage: "[BRANCH]",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "format", Value: utils.TableFormat, Usage: "Output format: table, json or yaml."},
+					},
+					Action: func(c *cli.Context) error {
+						return MustInitWorkflow(cfg, manifest).MassStatus(c.Args().First(), c.String("format"))
+					},
+				},
+				{
+					Name:      "merge",
+					Usage:     "Merges the approved, green and mergeable PRs of a campaign.",
+					ArgsUsage: "[BRANCH]",
 					Flags: []cli.Flag{
 						cli.BoolFlag{Name: noOperation, Usage: "Do not do any actions."},
 					},
 					Action: func(c *cli.Context) error {
-						if !utils.AskForConfirmation("You will create a PR for every changes made to the repo. Use `--noop` to check first. Continue?") {
+						return MustInitWorkflow(cfg, manifest).MassMerge(c.Args().First(), c.Bool(noOperation))
+					},
+				},
+				{
+					Name:      "close",
+					Usage:     "Abandons a campaign: closes its open PRs and deletes their remote and local branches.",
+					ArgsUsage: "[BRANCH]",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: noOperation, Usage: "Do not do any actions."},
+					},
+					Action: func(c *cli.Context) error {
+						if !c.Bool(noOperation) && !utils.AskForConfirmation("You will close every open PR of the campaign. Continue?") {
 							os.Exit(1)
 						}
-						return MustInitWorkflow(cfg, manifest).MassDone(c.Bool(noOperation))
+						return MustInitWorkflow(cfg, manifest).MassClose(c.Args().First(), c.Bool(noOperation))
 					},
 				},
 				{
 					Name:    "update",
 					Aliases: []string{"u"},
-					Usage:   "Clean the repository, checkout master and pull.",
-					Flags: []cli.Flag{
+					Usage:   "Clean the repository, checkout the default branch and pull.",
+					Flags: append(append(buildRepositoryOperationFlags(), buildRepositorySelectionFlags()...),
 						cli.BoolFlag{Name: unstash, Usage: unstashDesc},
-					},
+						cli.BoolFlag{Name: stash, Usage: stashDesc},
+						cli.BoolFlag{Name: safe, Usage: safeDesc},
+					),
 					Action: func(c *cli.Context) error {
-						if !utils.AskForConfirmation("You will lose existing changes.") {
+						sync, err := getSyncOptions(c)
+						if err != nil {
+							return err
+						}
+						if !sync.Safe && !utils.AskForConfirmation("You will lose existing changes. Use --safe to keep them.") {
 							os.Exit(1)
 						}
-						return MustInitWorkflow(cfg, manifest).MassUpdate(c.Bool(unstash))
+						return MustInitWorkflow(cfg, manifest).MassUpdate(getMassOperationOptions(c, cfg), sync)
 					},
 				},
 			},
 		},
 	}
 }
+
+func getMassOperationOptions(c *cli.Context, cfg *core.Configuration) core.RepositoryOperationOptions {
+	opts := getRepositoryOperationOptions(c, cfg)
+	opts.Selection = getRepositorySelection(c)
+	return opts
+}
+
+func getSyncOptions(c *cli.Context) (SyncOptions, error) {
+	opts := SyncOptions{Safe: c.Bool("safe"), Stash: c.Bool("stash"), Unstash: c.Bool("unstash")}
+	if opts.Stash && !opts.Safe {
+		return opts, errors.New("--stash requires --safe, use --unstash otherwise")
+	}
+	if opts.Unstash && opts.Safe {
+		return opts, errors.New("--unstash cannot be used with --safe, use --stash instead")
+	}
+	return opts, nil
+}
+
+// commit builds the conventional commit message from the branch, the flags and, in interactive mode, the prompts.
+func commit(cfg *core.Configuration, c *cli.Context, message string, extraArgs []string) error {
+	g := core.InitGit()
+	m := g.NewCommitMessage(message)
+	if c.String("type") != "" {
+		m.Type = c.String("type")
+	}
+	if scope := c.String("scope"); scope != "" {
+		if key := g.GetIssueKeyFromBranch(); key != "" && key != scope {
+			m.Refs = append(m.Refs, key)
+		}
+		m.Scope = scope
+	}
+	if c.String("body") != "" {
+		m.Body = c.String("body")
+	}
+	if c.String("breaking") != "" {
+		m.BreakingChange = c.String("breaking")
+	}
+	m.Refs = append(m.Refs, c.StringSlice("ref")...)
+	if c.Bool("interactive") {
+		var err error
+		if m, err = core.PromptCommitMessage(m); err != nil {
+			return err
+		}
+	}
+	return g.Commit(cfg, m, extraArgs)
+}
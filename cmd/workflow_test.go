@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/github"
+	"github.com/j-martin/nub/core"
+	"github.com/j-martin/nub/integrations/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDoneOutsideRepository runs done like the mass commands do, from the workspace which is not a repository.
+func TestDoneOutsideRepository(t *testing.T) {
+	workspace, err := ioutil.TempDir("", "nub-workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(workspace)
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(workspace))
+	defer os.Chdir(wd)
+
+	remote := path.Join(workspace, "remote.git")
+	assert.Nil(t, core.InitGit().RunGit("init", "-q", "--bare", remote))
+	g := core.MustInitGit("users")
+	assert.Nil(t, os.Mkdir("users", 0755))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"symbolic-ref", "HEAD", "refs/heads/master"},
+		{"config", "user.name", "test"},
+		{"config", "user.email", "test@example.com"},
+		{"remote", "add", "origin", "git@github.com:org/users.git"},
+		{"config", "url." + remote + ".insteadOf", "git@github.com:org/users.git"},
+	} {
+		assert.Nil(t, g.RunGit(args...))
+	}
+	assert.Nil(t, ioutil.WriteFile(path.Join("users", "CODEOWNERS"), []byte("* @owner\n"), 0644))
+	assert.Nil(t, g.RunGit("add", "CODEOWNERS"))
+	assert.Nil(t, g.RunGit("commit", "-q", "-m", "init"))
+	assert.Nil(t, g.RunGit("push", "-q", "origin", "master"))
+	assert.Nil(t, g.RunGit("fetch", "-q", "origin"))
+	branch := fmt.Sprintf("chore/ABC-1/test-done-%v", time.Now().UnixNano())
+	assert.Nil(t, g.RunGit("checkout", "-q", "-b", branch))
+	assert.Nil(t, ioutil.WriteFile(path.Join("users", "CODEOWNERS"), []byte("* @owner @other\n"), 0644))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/users/pulls":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"number": 7, "html_url": "https://github.com/org/users/pull/7", "base": {"repo": {"name": "users", "owner": {"login": "org"}}}}`)
+		case "/repos/org/users/pulls/7/requested_reviewers":
+			// the PR is still recorded when the reviewers cannot be requested.
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "Reviews may only be requested from collaborators."}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := gh.NewClient(nil)
+	client.BaseURL, err = url.Parse(server.URL + "/")
+	assert.Nil(t, err)
+	cfg := &core.Configuration{}
+	wf := &Workflow{cfg: cfg, github: github.InitGitHubWithClient(cfg, client)}
+
+	_, err = wf.done(context.Background(), "users", false)
+	assert.Nil(t, err)
+	campaign, err := core.LoadCampaign(branch)
+	assert.Nil(t, err)
+	if campaign != nil {
+		defer campaign.Delete()
+		assert.Equal(t, []core.CampaignPullRequest{{
+			Repository: "users",
+			Owner:      "org",
+			Number:     7,
+			URL:        "https://github.com/org/users/pull/7",
+		}}, campaign.PullRequests)
+	}
+}
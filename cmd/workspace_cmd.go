@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/j-martin/nub/core"
+	"github.com/j-martin/nub/integrations/github"
+	"github.com/j-martin/nub/utils"
+	"github.com/urfave/cli"
+)
+
+func buildWorkspaceCmds(cfg *core.Configuration) []cli.Command {
+	archived := "archived"
+	forks := "forks"
+	topic := "topic"
+	active := "active"
+	stash := "stash"
+	force := "force"
+	return []cli.Command{
+		{
+			Name:    "sync",
+			Aliases: []string{"s"},
+			Usage:   "Clone the missing repositories of the GitHub organization in the current directory and update the existing ones.",
+			Flags: append(buildRepositoryOperationFlags(),
+				cli.BoolFlag{Name: archived, Usage: "Include the archived repositories."},
+				cli.BoolFlag{Name: forks, Usage: "Include the forks."},
+				cli.StringSliceFlag{Name: topic, Usage: "Only the repositories with the topic. Can be repeated."},
+				cli.BoolFlag{Name: active, Usage: "Only the repositories with an active manifest."},
+				cli.BoolFlag{Name: stash, Usage: "Stash the changes of the dirty trees and restore them at the end of the update."},
+				cli.BoolFlag{Name: force, Usage: "Reset the existing repositories to the default branch instead of fast-forwarding them. The changes are lost unless --stash is set."},
+			),
+			Action: func(c *cli.Context) error {
+				filter := github.RepositoryFilter{
+					Archived: c.Bool(archived),
+					Forks:    c.Bool(forks),
+					Topics:   c.StringSlice(topic),
+					Active:   c.Bool(active),
+				}
+				if c.Bool(force) && !utils.AskForConfirmation("You will lose existing changes.") {
+					os.Exit(1)
+				}
+				return syncWorkspace(cfg, filter, getRepositoryOperationOptions(c, cfg), c.Bool(force), c.Bool(stash))
+			},
+		},
+	}
+}
+
+// syncWorkspace clones or updates the repositories of the organization and prints a summary.
+func syncWorkspace(cfg *core.Configuration, filter github.RepositoryFilter, opts core.RepositoryOperationOptions, force, stash bool) error {
+	repos, err := github.MustInitGitHub(cfg).ListOrganizationRepositories(filter)
+	if err != nil {
+		return err
+	}
+	var names []string
+	actions := map[string]string{}
+	for _, r := range repos {
+		name := r.GetName()
+		names = append(names, name)
+		actions[name] = "updated"
+		if exists, _ := utils.PathExists(name); !exists {
+			actions[name] = "cloned"
+		}
+	}
+	sort.Strings(names)
+	log.Printf("%v repositories to sync.", len(names))
+
+	results := core.RunConcurrentRepositoryOperations(names, opts, func(ctx context.Context, repoDir string) (string, error) {
+		return core.CloneOrSync(ctx, cfg, repoDir, force, stash)
+	})
+
+	rows := [][]string{{"REPOSITORY", "ACTION", "STATUS"}}
+	for _, result := range results {
+		status := "ok"
+		if result.Err != nil {
+			status = "failed: " + result.Error
+			log.Printf("%v:\n%v", result.Repository, result.Output)
+		}
+		rows = append(rows, []string{result.Repository, actions[result.Repository], status})
+	}
+	utils.PrintTable(rows)
+	if opts.Report != "" {
+		if err := results.WriteReport(opts.Report); err != nil {
+			return err
+		}
+	}
+	if len(results.Failed()) > 0 {
+		return errors.New("some repositories failed to sync")
+	}
+	return nil
+}
+
+func buildRepositoryOperationFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.IntFlag{Name: "concurrency", Usage: "Number of repositories processed at the same time. Defaults to workspace.concurrency of the config."},
+		cli.DurationFlag{Name: "timeout", Usage: "Time after which the commands run on a repository are killed, e.g. 5m. Defaults to workspace.timeout of the config."},
+		cli.StringFlag{Name: "report", Usage: "Write a JSON report of the results to the file, '-' for stdout."},
+	}
+}
+
+func getRepositoryOperationOptions(c *cli.Context, cfg *core.Configuration) core.RepositoryOperationOptions {
+	opts := core.RepositoryOperationOptions{
+		Concurrency: cfg.Workspace.Concurrency,
+		Timeout:     cfg.Workspace.Timeout,
+		Report:      c.String("report"),
+	}
+	if c.Int("concurrency") > 0 {
+		opts.Concurrency = c.Int("concurrency")
+	}
+	if c.Duration("timeout") > 0 {
+		opts.Timeout = c.Duration("timeout")
+	}
+	return opts
+}
+
+func buildRepositorySelectionFlags() []cli.Flag {
+	return append([]cli.Flag{
+		cli.StringSliceFlag{Name: "include", Usage: "Only the repositories matching the glob, e.g. 'api-*'. Can be repeated."},
+		cli.StringSliceFlag{Name: "exclude", Usage: "Skip the repositories matching the glob. Can be repeated."},
+		cli.StringFlag{Name: "repos-file", Usage: "File listing the repositories, one per line."},
+	}, buildManifestFilterFlags()...)
+}
+
+func getRepositorySelection(c *cli.Context) core.RepositorySelection {
+	return core.RepositorySelection{
+		Include:   c.StringSlice("include"),
+		Exclude:   c.StringSlice("exclude"),
+		ReposFile: c.String("repos-file"),
+		Manifest:  getManifestFilter(c),
+	}
+}
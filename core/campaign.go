@@ -0,0 +1,122 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// campaignMutex serializes the updates of the campaign files, the PRs are created concurrently.
+var campaignMutex sync.Mutex
+
+// Campaign tracks the PRs created across repositories for the same branch, e.g. with 'workflow mass done'.
+type Campaign struct {
+	Branch       string                `json:"branch"`
+	IssueKey     string                `json:"issueKey,omitempty"`
+	Created      time.Time             `json:"created"`
+	PullRequests []CampaignPullRequest `json:"pullRequests"`
+}
+
+type CampaignPullRequest struct {
+	Repository string `json:"repository"`
+	Owner      string `json:"owner"`
+	Number     int    `json:"number"`
+	URL        string `json:"url"`
+}
+
+func getCampaignsDir() string {
+	return GetConfigPath("campaigns")
+}
+
+func campaignPath(branch string) string {
+	return path.Join(getCampaignsDir(), strings.Replace(branch, "/", "_", -1)+".json")
+}
+
+func LoadCampaign(branch string) (*Campaign, error) {
+	data, err := ioutil.ReadFile(campaignPath(branch))
+	if os.IsNotExist(err) {
+		return nil, errors.Errorf("no campaign found for the branch '%v'", branch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Campaign{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, errors.Wrapf(err, "failed to load the campaign %v", branch)
+	}
+	return c, nil
+}
+
+func (c *Campaign) Save() error {
+	if err := os.MkdirAll(getCampaignsDir(), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(campaignPath(c.Branch), data, 0600)
+}
+
+// Delete removes the campaign file, the PRs and branches are not modified.
+func (c *Campaign) Delete() error {
+	return os.Remove(campaignPath(c.Branch))
+}
+
+// RecordCampaignPullRequest adds the PR to the campaign of the branch, the campaign is created if needed.
+func RecordCampaignPullRequest(branch, issueKey string, pr CampaignPullRequest) error {
+	campaignMutex.Lock()
+	defer campaignMutex.Unlock()
+	c, err := LoadCampaign(branch)
+	if err != nil {
+		c = &Campaign{Branch: branch, IssueKey: issueKey, Created: time.Now()}
+	}
+	for i, existing := range c.PullRequests {
+		if existing.Owner == pr.Owner && existing.Repository == pr.Repository {
+			c.PullRequests[i] = pr
+			return c.Save()
+		}
+	}
+	c.PullRequests = append(c.PullRequests, pr)
+	sort.Slice(c.PullRequests, func(i, j int) bool {
+		return c.PullRequests[i].Repository < c.PullRequests[j].Repository
+	})
+	return c.Save()
+}
+
+// ListCampaigns lists the branches of the campaigns, the most recent first.
+func ListCampaigns() ([]string, error) {
+	files, err := ioutil.ReadDir(getCampaignsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+	var branches []string
+	for _, f := range files {
+		if path.Ext(f.Name()) != ".json" {
+			continue
+		}
+		data, err := ioutil.ReadFile(path.Join(getCampaignsDir(), f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		c := Campaign{}
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, errors.Wrapf(err, "failed to load %v", f.Name())
+		}
+		branches = append(branches, c.Branch)
+	}
+	return branches, nil
+}
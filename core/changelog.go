@@ -0,0 +1,241 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const changelogHeader = "# Changelog"
+
+// Keep a Changelog sections, see https://keepachangelog.com.
+var changelogSections = []string{"Added", "Changed", "Deprecated", "Removed", "Fixed", "Security"}
+
+var changelogSectionByType = map[string]string{
+	"feat": "Added",
+	"fix":  "Fixed",
+}
+
+const securityScope = "security"
+
+var (
+	// e.g. 'remove the v1 endpoints'.
+	removalSubjectRegex     = regexp.MustCompile(`(?i)^(remove|drop|delete)\b`)
+	deprecationSubjectRegex = regexp.MustCompile(`(?i)^deprecate\b`)
+)
+
+var (
+	// e.g. 'feat: add the thing (#123)', the subject of squash merges.
+	squashPRRegex = regexp.MustCompile(`\s*\(#(\d+)\)$`)
+	prMergeRegex  = regexp.MustCompile(`^Merge pull request #\d+`)
+	// e.g. '## [1.2.0] - 2018-01-01', the Unreleased section is kept at the top.
+	changelogVersionRegex = regexp.MustCompile(`^## \[?([^\]\s]+)`)
+)
+
+type ChangelogIssue struct {
+	Key, Summary, URL string
+}
+
+type ChangelogPullRequest struct {
+	Number int
+	// the title replaces the subject of the merge commits without body.
+	Title, Author, URL string
+}
+
+type ChangelogEntry struct {
+	Hash, Type, Scope, Subject string
+	Breaking                   bool
+	Issues                     []ChangelogIssue
+	PullRequest                *ChangelogPullRequest
+}
+
+// setSubject sets the type, scope and subject from the conventional header, the header is the subject otherwise.
+func (e *ChangelogEntry) setSubject(header string) {
+	e.Type, e.Scope, e.Subject = "other", "", header
+	if match := commitHeaderRegex.FindStringSubmatch(header); match != nil {
+		e.Type, e.Scope, e.Subject = match[1], match[2], match[4]
+		e.Breaking = e.Breaking || match[3] == "!"
+	}
+}
+
+// SetPullRequest adds the details of the PR to the entry.
+func (e *ChangelogEntry) SetPullRequest(title, author, url string) {
+	e.PullRequest.Title, e.PullRequest.Author, e.PullRequest.URL = title, author, url
+	if title != "" && prMergeRegex.MatchString(e.Subject) {
+		e.setSubject(title)
+	}
+}
+
+// Section returns the Keep a Changelog section of the entry:
+//   - the breaking changes removing something, e.g. 'drop the v1 endpoints', are Removed.
+//   - the changes deprecating something, e.g. 'deprecate the v1 endpoints', are Deprecated.
+//   - the changes with the security scope are Security.
+//   - otherwise, feat is Added, fix is Fixed and the other types are Changed.
+func (e ChangelogEntry) Section() string {
+	switch {
+	case e.Breaking && removalSubjectRegex.MatchString(e.Subject):
+		return "Removed"
+	case deprecationSubjectRegex.MatchString(e.Subject):
+		return "Deprecated"
+	case strings.ToLower(e.Scope) == securityScope:
+		return "Security"
+	}
+	if section, ok := changelogSectionByType[e.Type]; ok {
+		return section
+	}
+	return "Changed"
+}
+
+func (e ChangelogEntry) String() string {
+	line := "- "
+	if e.Breaking {
+		line += "**BREAKING** "
+	}
+	// the issue keys are linked at the end.
+	if e.Scope != "" && !issueKeyRegex.MatchString(e.Scope) {
+		line += "**" + e.Scope + ":** "
+	}
+	line += e.Subject
+	if pr := e.PullRequest; pr != nil {
+		link := fmt.Sprintf("#%v", pr.Number)
+		if pr.URL != "" {
+			link = fmt.Sprintf("[#%v](%v)", pr.Number, pr.URL)
+		}
+		if pr.Author != "" {
+			link += " by @" + pr.Author
+		}
+		line += " (" + link + ")"
+	}
+	for _, i := range e.Issues {
+		issue := i.Key
+		if i.URL != "" {
+			issue = "[" + i.Key + "](" + i.URL + ")"
+		}
+		if i.Summary != "" {
+			issue += " " + i.Summary
+		}
+		line += " (" + issue + ")"
+	}
+	return line
+}
+
+// LogFirstParent returns the commits between the refs, without the commits of the merged branches.
+func (g *Git) LogFirstParent(from, to string) ([]*GitCommit, error) {
+	output, err := g.RunGitWithStdout("log", "--first-parent", "--pretty=format:%h||~||%an||~||%s||~||%b|~~~~~|", from+".."+to)
+	if err != nil {
+		return nil, err
+	}
+	var commits []*GitCommit
+	for _, line := range strings.Split(output, "|~~~~~|") {
+		fields := strings.Split(strings.TrimLeft(line, "\n"), "||~||")
+		if len(fields) != 4 {
+			continue
+		}
+		commits = append(commits, &GitCommit{Hash: fields[0], Committer: fields[1], Subject: fields[2], Body: strings.TrimSpace(fields[3])})
+	}
+	return commits, nil
+}
+
+// ParseChangelogEntries extracts the type, scope, PR and issue keys of the commits. The PR merge commits use the PR
+// title, their first body line, as the subject.
+func (g *Git) ParseChangelogEntries(commits []*GitCommit) []ChangelogEntry {
+	var entries []ChangelogEntry
+	for _, c := range commits {
+		e := ChangelogEntry{Hash: c.Hash, Breaking: strings.Contains(c.Body, breakingChangeFooter+":")}
+		header := c.Subject
+		if match := g.GetPRRegex().FindStringSubmatch(c.Subject); match != nil {
+			number, _ := strconv.Atoi(match[2])
+			e.PullRequest = &ChangelogPullRequest{Number: number}
+			if title := strings.SplitN(c.Body, "\n", 2)[0]; title != "" {
+				header = title
+			}
+		} else if match := squashPRRegex.FindStringSubmatch(c.Subject); match != nil {
+			number, _ := strconv.Atoi(match[1])
+			e.PullRequest = &ChangelogPullRequest{Number: number}
+			header = squashPRRegex.ReplaceAllString(c.Subject, "")
+		}
+		e.setSubject(header)
+		keys := map[string]bool{}
+		for _, key := range g.GetIssueIdRegex().FindAllString(c.Subject+"\n"+c.Body, -1) {
+			if !keys[key] {
+				keys[key] = true
+				e.Issues = append(e.Issues, ChangelogIssue{Key: key})
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// RenderChangelogSection renders the entries as a Keep a Changelog version section, the breaking changes first.
+func RenderChangelogSection(version string, date time.Time, entries []ChangelogEntry) string {
+	lines := []string{fmt.Sprintf("## [%v] - %v", version, date.Format("2006-01-02"))}
+	for _, section := range changelogSections {
+		var breaking, other []string
+		for _, e := range entries {
+			if e.Section() != section {
+				continue
+			}
+			if e.Breaking {
+				breaking = append(breaking, e.String())
+			} else {
+				other = append(other, e.String())
+			}
+		}
+		if len(breaking)+len(other) == 0 {
+			continue
+		}
+		lines = append(lines, "", "### "+section)
+		lines = append(lines, breaking...)
+		lines = append(lines, other...)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// PrependChangelog inserts the section before the latest version of the changelog, after the header and the
+// Unreleased section. The file is created if needed.
+func PrependChangelog(filePath, section string) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := strings.TrimRight(string(data), "\n")
+	if content == "" {
+		content = changelogHeader
+	}
+	lines := strings.Split(content, "\n")
+	index := len(lines)
+	for i, line := range lines {
+		if changelogVersionRegex.MatchString(line) && !strings.Contains(strings.ToLower(line), "unreleased") {
+			index = i
+			break
+		}
+	}
+	if version := changelogVersion(strings.SplitN(section, "\n", 2)[0]); version != "" {
+		for _, line := range lines {
+			if changelogVersion(line) == version {
+				return errors.Errorf("%v already contains the version '%v'", filePath, version)
+			}
+		}
+	}
+	before := strings.TrimRight(strings.Join(lines[:index], "\n"), "\n")
+	result := before + "\n\n" + section
+	if index < len(lines) {
+		result += "\n" + strings.Join(lines[index:], "\n") + "\n"
+	}
+	return ioutil.WriteFile(filePath, []byte(result), 0644)
+}
+
+// changelogVersion returns the version of the heading, e.g. 1.2.0 for '## [1.2.0] - 2018-01-01'.
+func changelogVersion(heading string) string {
+	if match := changelogVersionRegex.FindStringSubmatch(heading); match != nil {
+		return match[1]
+	}
+	return ""
+}
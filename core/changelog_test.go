@@ -0,0 +1,97 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangelogSection(t *testing.T) {
+	t.Parallel()
+	commits := []*GitCommit{
+		{Hash: "a1", Subject: "Merge pull request #12 from org/feat/ABC-1/thing", Body: "feat(ABC-1): add the thing"},
+		{Hash: "b2", Subject: "fix(api): handle the timeouts (#13)", Body: "Refs: ABC-2"},
+		{Hash: "c3", Subject: "refactor!: drop the v1 endpoints"},
+		{Hash: "d4", Subject: "Merge pull request #14 from org/some-branch"},
+		{Hash: "e5", Subject: "update the readme"},
+		{Hash: "f6", Subject: "feat: deprecate the v2 endpoints"},
+		{Hash: "g7", Subject: "fix(security): escape the search query"},
+	}
+	entries := InitGit().ParseChangelogEntries(commits)
+	assert.Len(t, entries, 7)
+	assert.Equal(t, "feat", entries[0].Type)
+	assert.Equal(t, 12, entries[0].PullRequest.Number)
+	assert.Equal(t, []ChangelogIssue{{Key: "ABC-1"}}, entries[0].Issues)
+	assert.Equal(t, "api", entries[1].Scope)
+	assert.Equal(t, 13, entries[1].PullRequest.Number)
+	assert.True(t, entries[2].Breaking)
+	assert.Equal(t, "other", entries[3].Type)
+
+	entries[0].Issues[0].Summary = "The thing"
+	entries[0].Issues[0].URL = "https://jira.example.com/browse/ABC-1"
+	entries[0].SetPullRequest("feat(ABC-1): add the thing", "dev", "https://github.com/org/repo/pull/12")
+	entries[3].SetPullRequest("fix: the branch", "dev", "")
+
+	date := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, `## [1.1.0] - 2018-01-02
+
+### Added
+- add the thing ([#12](https://github.com/org/repo/pull/12) by @dev) ([ABC-1](https://jira.example.com/browse/ABC-1) The thing)
+
+### Changed
+- update the readme
+
+### Deprecated
+- deprecate the v2 endpoints
+
+### Removed
+- **BREAKING** drop the v1 endpoints
+
+### Fixed
+- **api:** handle the timeouts (#13) (ABC-2)
+- the branch (#14 by @dev)
+
+### Security
+- **security:** escape the search query
+`, RenderChangelogSection("1.1.0", date, entries))
+}
+
+func TestPrependChangelog(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "nub-changelog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "CHANGELOG.md")
+
+	assert.Nil(t, PrependChangelog(file, "## [1.0.0] - 2018-01-01\n\n### Added\n- first\n"))
+	data, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "# Changelog\n\n## [1.0.0] - 2018-01-01\n\n### Added\n- first\n", string(data))
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte("# Changelog\n\n## [Unreleased]\n- pending\n\n## [1.0.0] - 2018-01-01\n\n### Added\n- first\n"), 0644))
+	assert.Nil(t, PrependChangelog(file, "## [1.1.0] - 2018-01-02\n\n### Fixed\n- second\n"))
+	data, err = ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, `# Changelog
+
+## [Unreleased]
+- pending
+
+## [1.1.0] - 2018-01-02
+
+### Fixed
+- second
+
+## [1.0.0] - 2018-01-01
+
+### Added
+- first
+`, string(data))
+
+	assert.NotNil(t, PrependChangelog(file, "## [1.1.0] - 2018-01-03\n"), "the version is already in the changelog")
+	assert.Nil(t, PrependChangelog(file, "## [1.1.1] - 2018-01-03\n"))
+}
@@ -0,0 +1,254 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/j-martin/nub/utils"
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+)
+
+const (
+	breakingChangeFooter = "BREAKING CHANGE"
+	refsFooter           = "Refs"
+	maxHeaderLength      = 100
+	bodyLineWidth        = 72
+	// everything below the line is removed by git, e.g. with 'git commit --verbose'.
+	scissorsLine = "# ------------------------ >8 ------------------------"
+)
+
+// CommitTypes are the conventional commit types accepted, the branch prefixes created from JIRA issues included.
+var CommitTypes = []string{"feat", "fix", "chore", "docs", "style", "refactor", "perf", "test", "build", "ci", "revert"}
+
+var (
+	// e.g. feat(ABC-123)!: add the thing
+	commitHeaderRegex = regexp.MustCompile(`^([a-z]+)(?:\(([^()\s]+)\))?(!)?: (\S.*)$`)
+	commitFooterRegex = regexp.MustCompile(`^(BREAKING CHANGE|BREAKING-CHANGE|[A-Za-z-]+)(?:: | #)(.*)$`)
+	// messages generated by git are not validated.
+	generatedCommitRegex = regexp.MustCompile(`^(Merge |Revert "|fixup! |squash! |amend! )`)
+	issueKeyRegex        = regexp.MustCompile(`^[A-Z]{2,}-\d+$`)
+)
+
+// CommitMessage is a conventional commit message, see https://www.conventionalcommits.org.
+type CommitMessage struct {
+	Type, Scope, Subject, Body string
+	// adds the ! marker to the header, the BreakingChange footer is optional.
+	Breaking       bool
+	BreakingChange string
+	// issue keys added as Refs footers.
+	Refs []string
+	// the other footers as written, e.g. 'Signed-off-by: someone <someone@example.com>'.
+	Footers []string
+}
+
+func (m CommitMessage) Header() string {
+	header := m.Type
+	if m.Scope != "" {
+		header += "(" + m.Scope + ")"
+	}
+	if m.Breaking || m.BreakingChange != "" {
+		header += "!"
+	}
+	return header + ": " + m.Subject
+}
+
+func (m CommitMessage) String() string {
+	paragraphs := []string{m.Header()}
+	if m.Body != "" {
+		for _, p := range strings.Split(m.Body, "\n\n") {
+			paragraphs = append(paragraphs, utils.ProperWordWrap(p, bodyLineWidth))
+		}
+	}
+	var footers []string
+	if m.BreakingChange != "" {
+		footers = append(footers, breakingChangeFooter+": "+m.BreakingChange)
+	}
+	for _, ref := range m.Refs {
+		footers = append(footers, refsFooter+": "+ref)
+	}
+	footers = append(footers, m.Footers...)
+	if len(footers) > 0 {
+		paragraphs = append(paragraphs, strings.Join(footers, "\n"))
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func (m CommitMessage) Validate() error {
+	if !utils.Contains(m.Type, CommitTypes...) {
+		return errors.Errorf("unknown commit type '%v', expected one of: %v", m.Type, strings.Join(CommitTypes, ", "))
+	}
+	if strings.TrimSpace(m.Subject) == "" {
+		return errors.New("the subject of the commit is empty")
+	}
+	if len(m.Header()) > maxHeaderLength {
+		return errors.Errorf("the header is %v characters long, the maximum is %v", len(m.Header()), maxHeaderLength)
+	}
+	return nil
+}
+
+// NewCommitMessage builds the message from the branch, e.g. feat/ABC-123/add-the-thing gives
+// 'feat(ABC-123): add the thing'. The subject overrides the one inferred from the branch, it is used as is if it is
+// already a conventional commit message.
+func (g *Git) NewCommitMessage(subject string) CommitMessage {
+	if m, err := ParseCommitMessage(subject); err == nil {
+		return *m
+	}
+	branch := g.GetCurrentBranch()
+	m := CommitMessage{
+		Type:    g.GetIssueTypeFromBranch(),
+		Scope:   g.GetIssueKeyFromBranch(),
+		Subject: strings.TrimSpace(subject),
+	}
+	if !utils.Contains(m.Type, CommitTypes...) {
+		m.Type = "chore"
+	}
+	if m.Subject == "" {
+		m.Subject = subjectFromBranchName(branch)
+	}
+	return m
+}
+
+// subjectFromBranchName drops the type and issue key segments of the branch name, e.g. feat/ABC-123/add-the-thing
+// gives 'add the thing'.
+func subjectFromBranchName(branch string) string {
+	segments := strings.Split(branch, "/")
+	if len(segments) > 1 && utils.Contains(segments[0], CommitTypes...) {
+		segments = segments[1:]
+	}
+	var kept []string
+	for _, s := range segments {
+		if issueKeyRegex.MatchString(s) {
+			continue
+		}
+		kept = append(kept, strings.Replace(s, "-", " ", -1))
+	}
+	return strings.Join(kept, " ")
+}
+
+// ParseCommitMessage parses the message, comments and what follows the scissors line are ignored like git does.
+func ParseCommitMessage(message string) (*CommitMessage, error) {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if line == scissorsLine {
+			break
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t\r"))
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, errors.New("the commit message is empty")
+	}
+	match := commitHeaderRegex.FindStringSubmatch(lines[0])
+	if match == nil {
+		return nil, errors.Errorf("'%v' does not follow the format 'type(scope)!: subject'", lines[0])
+	}
+	m := &CommitMessage{Type: match[1], Scope: match[2], Breaking: match[3] == "!", Subject: match[4]}
+	if len(lines) > 1 && lines[1] != "" {
+		return nil, errors.New("the header must be followed by a blank line")
+	}
+	paragraphs := strings.Split(strings.TrimSpace(strings.Join(lines[1:], "\n")), "\n\n")
+	if last := paragraphs[len(paragraphs)-1]; last != "" && isFooters(last) {
+		paragraphs = paragraphs[:len(paragraphs)-1]
+		// the footer the following lines continue, if any.
+		var continued *string
+		for _, footer := range strings.Split(last, "\n") {
+			f := commitFooterRegex.FindStringSubmatch(footer)
+			switch {
+			case f == nil:
+				if continued != nil {
+					*continued += "\n" + footer
+				}
+			case isBreakingChangeFooter(f[1]):
+				m.BreakingChange = f[2]
+				continued = &m.BreakingChange
+			case f[1] == refsFooter:
+				m.Refs = append(m.Refs, f[2])
+				continued = nil
+			default:
+				m.Footers = append(m.Footers, footer)
+				continued = &m.Footers[len(m.Footers)-1]
+			}
+		}
+	}
+	m.Body = strings.Join(paragraphs, "\n\n")
+	return m, m.Validate()
+}
+
+// isFooters returns true if every line of the paragraph is a footer, or the continuation of one. The continuations
+// are indented, except for the description of a breaking change. Otherwise, the paragraph is part of the body, e.g. a
+// paragraph starting with 'Note: '.
+func isFooters(paragraph string) bool {
+	breaking := false
+	for i, line := range strings.Split(paragraph, "\n") {
+		if f := commitFooterRegex.FindStringSubmatch(line); f != nil {
+			breaking = isBreakingChangeFooter(f[1])
+			continue
+		}
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		if i == 0 || !(breaking || indented) {
+			return false
+		}
+	}
+	return true
+}
+
+func isBreakingChangeFooter(token string) bool {
+	return token == breakingChangeFooter || token == "BREAKING-CHANGE"
+}
+
+// ValidateCommitMessage returns an error if the message is not a conventional commit. The messages generated by git,
+// e.g. merges and fixups, are accepted.
+func ValidateCommitMessage(message string) error {
+	if generatedCommitRegex.MatchString(message) {
+		return nil
+	}
+	_, err := ParseCommitMessage(message)
+	return err
+}
+
+// PromptCommitMessage asks for every part of the message, the defaults are pre-filled.
+func PromptCommitMessage(defaults CommitMessage) (CommitMessage, error) {
+	m := defaults
+	types := []string{m.Type}
+	for _, t := range CommitTypes {
+		if t != m.Type {
+			types = append(types, t)
+		}
+	}
+	var err error
+	if m.Type, err = utils.PickItem("Type", types); err != nil {
+		return m, err
+	}
+	ask := func(label, value string, validate promptui.ValidateFunc) (string, error) {
+		prompt := promptui.Prompt{Label: label, Default: value, AllowEdit: true, Validate: validate}
+		result, err := prompt.Run()
+		return strings.TrimSpace(result), err
+	}
+	notEmpty := func(input string) error {
+		if strings.TrimSpace(input) == "" {
+			return errors.New("required")
+		}
+		return nil
+	}
+	if m.Scope, err = ask("Scope (optional)", m.Scope, nil); err != nil {
+		return m, err
+	}
+	if m.Subject, err = ask("Subject", m.Subject, notEmpty); err != nil {
+		return m, err
+	}
+	if m.Body, err = ask("Body (optional)", m.Body, nil); err != nil {
+		return m, err
+	}
+	if m.BreakingChange, err = ask("Breaking change (optional)", m.BreakingChange, nil); err != nil {
+		return m, err
+	}
+	fmt.Printf("\n%v\n\n", m)
+	return m, m.Validate()
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommitMessageString(t *testing.T) {
+	t.Parallel()
+	m := CommitMessage{Type: "feat", Scope: "ABC-123", Subject: "add the thing"}
+	assert.Equal(t, "feat(ABC-123): add the thing", m.String())
+
+	m.Body = "first paragraph\n\nsecond paragraph"
+	m.BreakingChange = "the thing replaces the other one"
+	m.Refs = []string{"ABC-124"}
+	assert.Equal(t, `feat(ABC-123)!: add the thing
+
+first paragraph
+
+second paragraph
+
+BREAKING CHANGE: the thing replaces the other one
+Refs: ABC-124`, m.String())
+
+	parsed, err := ParseCommitMessage(m.String())
+	assert.Nil(t, err)
+	assert.Equal(t, CommitMessage{
+		Type:           "feat",
+		Scope:          "ABC-123",
+		Subject:        "add the thing",
+		Body:           "first paragraph\n\nsecond paragraph",
+		Breaking:       true,
+		BreakingChange: "the thing replaces the other one",
+		Refs:           []string{"ABC-124"},
+	}, *parsed)
+}
+
+func TestParseCommitMessageFooters(t *testing.T) {
+	t.Parallel()
+	message := `fix: handle the empty response
+
+Note: the API returns 204 when there is nothing, which was treated as
+an error.
+
+Refs: ABC-123
+Signed-off-by: Someone <someone@example.com>
+Co-authored-by: Other <other@example.com>
+Reviewed-by: Third
+  <third@example.com>`
+	m, err := ParseCommitMessage(message)
+	assert.Nil(t, err)
+	assert.Equal(t, "Note: the API returns 204 when there is nothing, which was treated as\nan error.", m.Body)
+	assert.Equal(t, []string{"ABC-123"}, m.Refs)
+	assert.Equal(t, []string{
+		"Signed-off-by: Someone <someone@example.com>",
+		"Co-authored-by: Other <other@example.com>",
+		"Reviewed-by: Third\n  <third@example.com>",
+	}, m.Footers)
+	assert.Equal(t, message, m.String())
+}
+
+func TestValidateCommitMessage(t *testing.T) {
+	t.Parallel()
+	valid := []string{
+		"fix: typo",
+		"feat(ABC-1)!: drop the old API\n",
+		"chore(deps): bump\n\nbody\n# Please enter the commit message\n",
+		"docs: readme\n" + scissorsLine + "\ndiff --git a/README.md b/README.md",
+		"Merge branch 'master' into feature",
+		"fixup! feat: add the thing",
+	}
+	for _, message := range valid {
+		assert.Nil(t, ValidateCommitMessage(message), message)
+	}
+	invalid := []string{
+		"",
+		"# only a comment",
+		"add the thing",
+		"feature: add the thing",
+		"feat(ABC-1):add the thing",
+		"feat: add the thing\nno blank line",
+	}
+	for _, message := range invalid {
+		assert.NotNil(t, ValidateCommitMessage(message), message)
+	}
+}
+
+func TestSubjectFromBranchName(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "add the thing", subjectFromBranchName("feat/ABC-123/add-the-thing"))
+	assert.Equal(t, "add the thing", subjectFromBranchName("fix/add-the-thing"))
+	assert.Equal(t, "some branch", subjectFromBranchName("some-branch"))
+}
@@ -8,6 +8,7 @@ import (
 	"os/user"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/imdario/mergo"
 	"github.com/j-martin/nub/utils"
@@ -34,14 +35,29 @@ type User struct {
 type Configuration struct {
 	Git struct {
 		NoVerify bool `yaml:"noVerify"`
+		// Template of the URL used to clone the repositories.
+		CloneURL string `yaml:"cloneUrl"`
+		// Remote the branches are pushed to, origin by default.
+		PushRemote string `yaml:"pushRemote"`
+		// How 'workflow update-branch' updates the branch: rebase (default) or merge.
+		UpdateStrategy string `yaml:"updateStrategy"`
 	}
 	GitHub struct {
 		Organization, Username, Token string
 		Reviewers                     []string
 	}
-	Users []User
-	Confluence ServiceConfiguration
-	JIRA  struct {
+	Users      []User
+	Confluence ConfluenceConfiguration
+	Manifest   struct {
+		Types []string
+	}
+	Workspace struct {
+		// Number of repositories processed at the same time by the mass operations.
+		Concurrency int
+		// Time after which the operation on a repository is abandoned, e.g. 5m.
+		Timeout time.Duration
+	}
+	JIRA struct {
 		Server, Username, Password string
 		Project, Board             string
 		Transitions                []JIRATransition
@@ -58,8 +74,29 @@ type ServiceConfiguration struct {
 	Server, Username, Password string
 }
 
+type ConfluenceConfiguration struct {
+	ServiceConfiguration `yaml:",inline"`
+	// Space and parent page under which the documentation pages are created.
+	Space        string
+	ParentPageId string `yaml:"parentPageId"`
+	// Template of the header of the documentation pages, the default is used if empty.
+	Template string
+	// Links added to the header of the documentation pages, the URLs are templates.
+	Links map[string]string
+}
+
 var config = `---
 # use 'nub config --shared' to edit the shared config.
+git:
+	# template of the URL used to clone the repositories. Variables: .Organization, .Repository
+	# cloneUrl: "https://github.com/{{ .Organization }}/{{ .Repository }}.git"
+	# remote the branches are pushed to. When working on a fork, the 'upstream' remote is used as the canonical one.
+	# pushRemote: origin
+	# how 'workflow update-branch' updates the current branch with the default branch: rebase or merge.
+	# updateStrategy: rebase
+	# skips the git hooks, including the ones installed by 'nub hooks install', when nub commits and pushes.
+	# noVerify: false
+
 github:
 	organization: nestoca
 	reviewers:
@@ -67,6 +104,33 @@ github:
 
 confluence:
 	server: "https://example.atlassian.net/wiki"
+	# space (key) and parent page under which the documentation pages are created with 'nub confluence publish --create'.
+	space:
+	parentPageId:
+	# Header of the pages published with 'nub confluence publish', a Go template in the Confluence storage format.
+	# It can be overridden per repository with 'documentation.template' in the manifest. Variables:
+	#   .Manifest           the manifest of the repository, e.g. .Manifest.Name, .Manifest.Deploy.Environment
+	#   .Organization       the GitHub organization
+	#   .Repository         the repository name
+	#   .RepositoryURL      the GitHub URL of the repository
+	#   .Links              the rendered links below, by name
+	#   .MarshaledManifest  the manifest as YAML
+	# template: |
+	#   <p><a href="{{ .RepositoryURL }}">Repository</a>{{ range $name, $url := .Links }} | <a href="{{ $url }}">{{ $name }}</a>{{ end }}</p>
+	links:
+		# Links shown in the header of the pages. The URLs are templates with the same variables, except .Links.
+		# CI: "https://ci.example.com/job/{{ .Organization }}/job/{{ .Repository }}"
+		# Logs: "https://logs.example.com/search?q={{ .Manifest.Deploy.Environment }}-{{ .Manifest.Name }}"
+
+workspace:
+	# number of repositories processed at the same time by the mass/workspace commands. Defaults to 8.
+	concurrency:
+	# time after which the operation on a repository is abandoned, e.g. 5m. No timeout by default.
+	timeout:
+
+manifest:
+	# types allowed in the manifests. Defaults to: service, library, application, tool, website, etc.
+	types:
 
 jira:
 	server: "https://example.atlassian.net"
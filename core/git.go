@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -8,7 +9,6 @@ import (
 	"path"
 	"regexp"
 	"strings"
-	"sync"
 	"text/tabwriter"
 
 	"github.com/j-martin/nub/utils"
@@ -20,13 +20,26 @@ type Git struct {
 	cfg           *Configuration
 	dir           string
 	currentBranch string
+	defaultBranch string
+	// remote of the canonical repository, see GetUpstreamRemoteName.
+	upstreamRemote string
+	// cancelling it kills the git commands running, see MustInitGitWithContext.
+	ctx context.Context
 }
 
+const (
+	defaultRemote = "origin"
+	// used when the default branch of the remote cannot be detected.
+	fallbackDefaultBranch = "master"
+)
+
 type GitCommit struct {
 	Hash, Committer, Subject, Body string
 }
 
-type RepoOperation func(string) (string, error)
+// RepoOperation runs on a repository, the commands it starts must be cancelled with the context, e.g. with
+// MustInitGitWithContext.
+type RepoOperation func(context.Context, string) (string, error)
 
 func InitGit() *Git {
 	return &Git{}
@@ -39,26 +52,40 @@ func MustInitGit(repoDir string) *Git {
 	return &Git{dir: repoDir}
 }
 
+// MustInitGitWithContext is MustInitGit, the git commands are killed when the context is cancelled.
+func MustInitGitWithContext(ctx context.Context, repoDir string) *Git {
+	g := MustInitGit(repoDir)
+	g.ctx = ctx
+	return g
+}
+
+func (g *Git) context() context.Context {
+	if g.ctx == nil {
+		return context.Background()
+	}
+	return g.ctx
+}
+
 func (g *Git) RunGit(args ...string) error {
 	if g.dir != "" {
 		args = append([]string{"-C", g.dir}, args...)
 	}
 	log.Printf("Running: 'git %v'", strings.Join(args, " "))
-	return utils.RunCmd("git", args...)
+	return utils.RunCmdContext(g.context(), "git", args...)
 }
 
 func (g *Git) RunGitWithStdout(args ...string) (string, error) {
 	if g.dir != "" {
 		args = append([]string{"-C", g.dir}, args...)
 	}
-	return utils.RunCmdWithStdout("git", args...)
+	return utils.RunCmdWithStdoutContext(g.context(), "git", args...)
 }
 
 func (g *Git) RunGitWithFullOutput(args ...string) (string, error) {
 	if g.dir != "" {
 		args = append([]string{"-C", g.dir}, args...)
 	}
-	return utils.RunCmdWithFullOutput("git", args...)
+	return utils.RunCmdWithFullOutputContext(g.context(), "git", args...)
 }
 
 func (g *Git) MustRunGitWithStdout(args ...string) string {
@@ -70,8 +97,29 @@ func (g *Git) MustRunGitWithStdout(args ...string) string {
 }
 
 func (g *Git) GetCurrentRepositoryName() string {
-	repositoryUri := g.MustRunGitWithStdout("config", "--get", "remote.origin.url")
-	return strings.TrimSuffix(path.Base(repositoryUri), path.Ext(repositoryUri))
+	name, err := g.GetRepositoryName()
+	if err != nil {
+		log.Fatalf("Git failed: %v", err)
+	}
+	return name
+}
+
+func (g *Git) GetRepositoryName() (string, error) {
+	remote, err := g.GetUpstreamRemote()
+	if err != nil && remote.URL == "" {
+		return "", err
+	}
+	if err != nil {
+		// e.g. local remotes, the name of the directory is used.
+		return strings.TrimSuffix(path.Base(remote.URL), path.Ext(remote.URL)), nil
+	}
+	return remote.Repository, nil
+}
+
+// GetRepositoryOwner returns the owner (organization or user) of the canonical repository.
+func (g *Git) GetRepositoryOwner() (string, error) {
+	remote, err := g.GetUpstreamRemote()
+	return remote.Owner, err
 }
 
 func (g *Git) GetCurrentBranch() string {
@@ -83,22 +131,75 @@ func (g *Git) GetCurrentBranch() string {
 	return g.currentBranch
 }
 
+// GetDefaultBranch returns the default branch of the repository, e.g. master or main. The defaultBranch of the
+// manifest takes precedence over the HEAD of the remote.
+func (g *Git) GetDefaultBranch() string {
+	if g.defaultBranch != "" {
+		return g.defaultBranch
+	}
+	g.defaultBranch = g.detectDefaultBranch()
+	return g.defaultBranch
+}
+
+func (g *Git) detectDefaultBranch() string {
+	if root, err := g.GetRepositoryRootPath(); err == nil {
+		if branch := readManifestDefaultBranch(root); branch != "" {
+			return branch
+		}
+	}
+	remote := g.GetUpstreamRemoteName()
+	remoteHead, err := g.RunGitWithStdout("symbolic-ref", "--short", "-q", "refs/remotes/"+remote+"/HEAD")
+	if err == nil && strings.TrimSpace(remoteHead) != "" {
+		return strings.TrimPrefix(strings.TrimSpace(remoteHead), remote+"/")
+	}
+	for _, branch := range []string{"main", fallbackDefaultBranch} {
+		if _, err := g.RunGitWithStdout("rev-parse", "--verify", "-q", "refs/remotes/"+remote+"/"+branch); err == nil {
+			return branch
+		}
+	}
+	return fallbackDefaultBranch
+}
+
+// GetDefaultBranchRef returns the remote reference of the default branch, e.g. origin/master or upstream/main.
+func (g *Git) GetDefaultBranchRef() string {
+	return g.GetUpstreamRemoteName() + "/" + g.GetDefaultBranch()
+}
+
 func (g *Git) GetRepositoryRootPath() (string, error) {
 	return g.RunGitWithStdout("rev-parse", "--show-toplevel")
 }
 
+// GetTitleFromBranchName replaces the hyphens of the branch name by spaces, except in the issue key, e.g.
+// feat/ABC-123/add-the-thing gives 'feat/ABC-123/add the thing'.
 func (g *Git) GetTitleFromBranchName() string {
-	branch := g.GetCurrentBranch()
-	return strings.Replace(strings.Replace(strings.Replace(branch, "-", "_", 1), "-", " ", -1), "_", "-", -1)
+	segments := strings.Split(g.GetCurrentBranch(), "/")
+	for i, s := range segments {
+		if !issueKeyRegex.MatchString(s) {
+			segments[i] = strings.Replace(s, "-", " ", -1)
+		}
+	}
+	return strings.Join(segments, "/")
 }
 
-func (g *Git) Clone() (string, error) {
-	log.Printf("Cloning: %v", g.dir)
-	return utils.RunCmdWithFullOutput("git", "clone", "git@github.com:benchlabs/"+g.dir+".git")
+func (g *Git) Clone(cfg *Configuration) (string, error) {
+	cloneURL, err := CloneURL(cfg, g.dir)
+	if err != nil {
+		return "", err
+	}
+	existed, _ := utils.PathExists(g.dir)
+	log.Printf("Cloning: %v from %v", g.dir, cloneURL)
+	output, err := utils.RunCmdWithFullOutputContext(g.context(), "git", "clone", cloneURL, g.dir)
+	if err != nil && !existed {
+		// the clone may be partial if it was killed, e.g. on timeout.
+		if removeErr := os.RemoveAll(g.dir); removeErr != nil {
+			log.Printf("Failed to remove the partial clone %v: %v", g.dir, removeErr)
+		}
+	}
+	return output, err
 }
 
 func (g *Git) Push(cfg *Configuration) error {
-	args := []string{"push", "--set-upstream", "origin", g.GetCurrentBranch()}
+	args := []string{"push", "--set-upstream", g.GetPushRemoteName(cfg), g.GetCurrentBranch()}
 	if cfg.Git.NoVerify {
 		args = append(args, "--no-verify")
 	}
@@ -106,20 +207,21 @@ func (g *Git) Push(cfg *Configuration) error {
 }
 
 func (g *Git) Sync(unStash bool) (string, error) {
+	defaultBranch := g.GetDefaultBranch()
 	commands := [][]string{
 		{"reset", "HEAD", g.dir},
 	}
 	dirtyTree := g.RunGit("diff-index", "--quiet", "HEAD", "--") != nil
 	if dirtyTree {
 		commands = append(commands, [][]string{
-			{"checkout", "master", "-f"},
+			{"checkout", defaultBranch, "-f"},
 			{"stash", "save", "pre-update-" + utils.CurrentTimeForFilename()},
 		}...)
 	}
 	commands = append(commands, [][]string{
-		{"checkout", "master", "-f"},
+		{"checkout", defaultBranch, "-f"},
 		{"clean", "-fd"},
-		{"checkout", "master", "."},
+		{"checkout", defaultBranch, "."},
 		{"pull"},
 		{"pull", "--tags"},
 	}...)
@@ -135,53 +237,136 @@ func (g *Git) Sync(unStash bool) (string, error) {
 	return "", nil
 }
 
-type ConcurrentResult struct {
-	Output string
-	Err    error
-}
-
-type ConcurrentResults map[string]ConcurrentResult
-
-func ConcurrentRepositoryOperations(repos []string, fn RepoOperation) error {
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
-	errs := ConcurrentResults{}
-	for _, r := range repos {
-		log.Printf("Sync: %v", r)
-		wg.Add(1)
-		go func(repo string) {
-			defer wg.Done()
-			output, err := fn(repo)
-			mutex.Lock()
-			errs[repo] = ConcurrentResult{Output: output, Err: err}
-			mutex.Unlock()
-			log.Printf("%v: done.", repo)
-		}(r)
-	}
-	wg.Wait()
-	errorCount := 0
-	for repo, result := range errs {
-		fmt.Println(result.Output)
-		if result.Err != nil {
-			errorCount++
-			log.Printf("%v failed to be updated: %v", repo, result.Err)
+// SafeSync fast-forwards the default branch without losing any change. Dirty trees are refused unless stash is set,
+// in which case the changes, including the untracked files, are stashed and restored. The original branch is checked
+// out at the end.
+func (g *Git) SafeSync(stash bool) (output string, err error) {
+	original := g.GetCurrentBranch()
+	if original == "" {
+		return "", errors.New("HEAD is detached, checkout a branch first")
+	}
+	dirty := g.ContainedUncommittedChanges()
+	if dirty && !stash {
+		return "", errors.New("the tree contains uncommitted changes, commit them or use --stash to stash them")
+	}
+	run := func(args ...string) error {
+		out, err := g.RunGitWithFullOutput(args...)
+		output += out + "\n"
+		return err
+	}
+	if dirty {
+		if err := run("stash", "push", "--include-untracked", "-m", "nub-sync-"+utils.CurrentTimeForFilename()); err != nil {
+			return output, err
 		}
 	}
-	if errorCount > 0 {
-		log.Printf("%v repos failed to be updated.", errorCount)
-		return errors.New("some repos failed to update")
+	defer func() {
+		if original != g.GetDefaultBranch() {
+			if restoreErr := run("checkout", original); restoreErr != nil && err == nil {
+				err = restoreErr
+			}
+		}
+		if dirty {
+			if restoreErr := run("stash", "pop"); restoreErr != nil && err == nil {
+				err = errors.Wrap(restoreErr, "failed to restore the stashed changes, see 'git stash list'")
+			}
+		}
+	}()
+	for _, cmd := range [][]string{
+		{"checkout", g.GetDefaultBranch()},
+		{"pull", "--ff-only"},
+		{"fetch", "--tags"},
+	} {
+		if err := run(cmd...); err != nil {
+			return output, err
+		}
 	}
-	log.Print("All Done.")
-	return nil
+	return output, nil
 }
 
-func (g *Git) syncRepository() (string, error) {
-	repositoryExists, _ := utils.PathExists(g.dir)
-	if repositoryExists {
-		return g.Sync(true)
-	} else {
-		return g.Clone()
+const (
+	UpdateStrategyRebase = "rebase"
+	UpdateStrategyMerge  = "merge"
+)
+
+// UpdateBranch rebases, or merges, the current branch onto the default branch of the upstream remote. On conflicts,
+// the rebase or merge is left in progress and the conflicting files are returned with the error.
+func (g *Git) UpdateBranch(strategy string) (conflicts []string, err error) {
+	branch := g.GetCurrentBranch()
+	if branch == "" {
+		return nil, errors.New("HEAD is detached, checkout a branch first")
+	}
+	if branch == g.GetDefaultBranch() {
+		return nil, errors.Errorf("already on the default branch '%v', use 'sync' instead", branch)
+	}
+	if g.ContainedUncommittedChanges() {
+		return nil, errors.New("the tree contains uncommitted changes, commit or stash them first")
+	}
+	if err := g.RunGit("fetch", g.GetUpstreamRemoteName()); err != nil {
+		return nil, err
+	}
+	switch strategy {
+	case "", UpdateStrategyRebase:
+		err = g.RunGit("rebase", g.GetDefaultBranchRef())
+	case UpdateStrategyMerge:
+		err = g.RunGit("merge", "--no-edit", g.GetDefaultBranchRef())
+	default:
+		return nil, errors.Errorf("unknown update strategy '%v', expected %v or %v", strategy, UpdateStrategyRebase, UpdateStrategyMerge)
+	}
+	if err == nil {
+		return nil, nil
 	}
+	conflicts, listErr := g.ListConflictedFiles()
+	if listErr != nil || len(conflicts) == 0 {
+		return nil, err
+	}
+	return conflicts, errors.Errorf("%v of %v files conflicted", len(conflicts), g.GetDefaultBranchRef())
+}
+
+// AbortUpdateBranch aborts the rebase or merge left in progress by UpdateBranch.
+func (g *Git) AbortUpdateBranch(strategy string) error {
+	if strategy == UpdateStrategyMerge {
+		return g.RunGit("merge", "--abort")
+	}
+	return g.RunGit("rebase", "--abort")
+}
+
+func (g *Git) ListConflictedFiles() ([]string, error) {
+	output, err := g.RunGitWithStdout("diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range strings.Split(output, "\n") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// ForcePushWithLease pushes the rewritten branch, unless the remote branch is no longer at the expected hash. The
+// expected hash has to be read with GetRemoteBranchHash before fetching, otherwise the lease holds whatever was pushed
+// in the meantime. An empty hash expects the branch not to exist on the remote.
+func (g *Git) ForcePushWithLease(cfg *Configuration, branch, expected string) error {
+	args := []string{"push", "--force-with-lease=" + branch + ":" + expected, g.GetPushRemoteName(cfg), branch}
+	if cfg.Git.NoVerify {
+		args = append(args, "--no-verify")
+	}
+	return g.RunGit(args...)
+}
+
+// CloneOrSync clones the repository in repoDir if it does not exist yet, otherwise it fast-forwards it with SafeSync.
+// Forcing it resets the repository with Sync instead, the changes are lost unless stashed.
+func CloneOrSync(ctx context.Context, cfg *Configuration, repoDir string, force, stash bool) (string, error) {
+	g := MustInitGitWithContext(ctx, repoDir)
+	repositoryExists, _ := utils.PathExists(repoDir)
+	if !repositoryExists {
+		return g.Clone(cfg)
+	}
+	if force {
+		return g.Sync(stash)
+	}
+	return g.SafeSync(stash)
 }
 
 func (g *Git) Log() (commits []*GitCommit) {
@@ -282,15 +467,15 @@ func (g *Git) sanitizeBranchName(name string) string {
 }
 
 func (g *Git) LogNotInMasterSubjects() []string {
-	return strings.Split(g.MustRunGitWithStdout("log", "HEAD", "--not", "origin/master", "--no-merges", "--pretty=format:%s"), "\n")
+	return strings.Split(g.MustRunGitWithStdout("log", "HEAD", "--not", g.GetDefaultBranchRef(), "--no-merges", "--pretty=format:%s"), "\n")
 }
 
 func (g *Git) LogNotInMasterBody() string {
-	return g.MustRunGitWithStdout("log", "HEAD", "--not", "origin/master", "--no-merges", "--pretty=format:-> %B")
+	return g.MustRunGitWithStdout("log", "HEAD", "--not", g.GetDefaultBranchRef(), "--no-merges", "--pretty=format:-> %B")
 }
 
 func (g *Git) ListFileChanged() []string {
-	return strings.Split(g.MustRunGitWithStdout("diff", "HEAD", "--not", "origin/master", "--name-only"), "\n")
+	return strings.Split(g.MustRunGitWithStdout("diff", "HEAD", "--not", g.GetDefaultBranchRef(), "--name-only"), "\n")
 }
 
 func (g *Git) GetIssueKeyFromBranch() string {
@@ -305,33 +490,64 @@ func (g *Git) CommitWithBranchName() error {
 	return g.RunGit("commit", "-m", g.GetTitleFromBranchName(), "--all")
 }
 
+// CommitAll commits every change, including the untracked files.
+func (g *Git) CommitAll(cfg *Configuration, message string) error {
+	if err := g.RunGit("add", "--all"); err != nil {
+		return err
+	}
+	args := []string{"commit", "-m", message}
+	if cfg.Git.NoVerify {
+		args = append(args, "--no-verify")
+	}
+	return g.RunGit(args...)
+}
+
+// StagedDiff stages every change, including the untracked files, and returns the diff.
+func (g *Git) StagedDiff() (string, error) {
+	if _, err := g.RunGitWithFullOutput("add", "--all"); err != nil {
+		return "", err
+	}
+	return g.RunGitWithStdout("--no-pager", "diff", "--cached")
+}
+
+// DiscardBranch drops the changes and the branch, and goes back to the branch given, the default branch if empty.
+func (g *Git) DiscardBranch(name, checkout string) error {
+	if checkout == "" {
+		checkout = g.GetDefaultBranch()
+	}
+	for _, cmd := range [][]string{
+		{"reset", "--hard", "HEAD"},
+		{"clean", "-fd"},
+		{"checkout", checkout},
+		{"branch", "-D", g.sanitizeBranchName(name)},
+	} {
+		if output, err := g.RunGitWithFullOutput(cmd...); err != nil {
+			return errors.Wrap(err, output)
+		}
+	}
+	return nil
+}
+
 func (g *Git) CurrentHEAD() (string, error) {
 	return g.RunGitWithStdout("rev-parse", "HEAD")
 }
 
+// CommitWithIssueKey commits with a conventional message built from the branch, e.g. 'feat(ABC-123): message'. The
+// message is inferred from the branch name if empty.
 func (g *Git) CommitWithIssueKey(cfg *Configuration, message string, extraArgs []string) error {
-	issueKey := g.GetIssueKeyFromBranch()
-	issueType := g.GetIssueTypeFromBranch()
-	if message == "" {
-		title := g.GetTitleFromBranchName()
-		pos := strings.Index(title, " ")
-		if pos < 0 {
-			return errors.New("commit message could not be inferred from branch name")
-		}
-		message = title[pos:]
-	}
-	message = strings.Trim(message, " ")
-	if len(message) == 0 {
+	m := g.NewCommitMessage(message)
+	if m.Subject == "" {
 		return errors.New("no commit message passed or could not be inferred from branch name")
 	}
-	if issueKey != "" {
-		message = issueType + "(" + issueKey + "): " + message
-	} else if issueType != "" {
-		message = issueType + ": " + message
+	return g.Commit(cfg, m, extraArgs)
+}
+
+func (g *Git) Commit(cfg *Configuration, m CommitMessage, extraArgs []string) error {
+	if err := m.Validate(); err != nil {
+		return err
 	}
-	message = utils.ProperWordWrap(message, 72)
 	args := []string{
-		"commit", "-m", message,
+		"commit", "-m", m.String(),
 	}
 	if cfg.Git.NoVerify {
 		args = append(args, "--no-verify")
@@ -350,12 +566,33 @@ func (g *Git) extractIssueTypeFromName(name string) string {
 
 func (g *Git) CreateBranch(name string) error {
 	name = g.sanitizeBranchName(name)
-	return g.RunGit("checkout", "-b", name, "origin/master")
+	return g.RunGit("checkout", "-b", name, g.GetDefaultBranchRef())
 }
 
 func (g *Git) ForceCreateBranch(name string) error {
 	name = g.sanitizeBranchName(name)
-	return g.RunGit("checkout", "-B", name, "origin/master")
+	return g.RunGit("checkout", "-B", name, g.GetDefaultBranchRef())
+}
+
+// CreateBranchFromDefault creates the branch from the latest default branch of the upstream remote without touching
+// the other branches. It fails if the branch already exists, unless forced in which case the branch is reset.
+func (g *Git) CreateBranchFromDefault(name string, force bool) error {
+	name = g.sanitizeBranchName(name)
+	if g.BranchExists(name) && !force {
+		return errors.Errorf("the branch '%v' already exists, use --force to reset it", name)
+	}
+	if err := g.RunGit("fetch", g.GetUpstreamRemoteName()); err != nil {
+		return err
+	}
+	create := "-b"
+	if force {
+		create = "-B"
+	}
+	if err := g.RunGit("checkout", create, name, g.GetDefaultBranchRef()); err != nil {
+		return err
+	}
+	g.currentBranch = name
+	return nil
 }
 
 func (g *Git) CheckoutBranch() error {
@@ -366,11 +603,11 @@ func (g *Git) CheckoutBranch() error {
 	return g.RunGit("checkout", item)
 }
 
-func ForEachRepo(fn RepoOperation) error {
-	var repos []string
+// ListRepositories lists the git repositories in the current directory.
+func ListRepositories() (repos []string, err error) {
 	files, err := ioutil.ReadDir("./")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for _, value := range files {
 		if !value.IsDir() {
@@ -381,7 +618,79 @@ func ForEachRepo(fn RepoOperation) error {
 		}
 		repos = append(repos, value.Name())
 	}
-	return ConcurrentRepositoryOperations(repos, fn)
+	return repos, nil
+}
+
+type LocalBranch struct {
+	Name, Upstream, Hash string
+	// the upstream branch was deleted, e.g. after the PR was merged.
+	Gone bool
+}
+
+func (g *Git) ListLocalBranches() ([]LocalBranch, error) {
+	output, err := g.RunGitWithStdout("for-each-ref", "--format=%(refname:short)|%(upstream:short)|%(upstream:track)|%(objectname)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	var branches []LocalBranch
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) != 4 {
+			continue
+		}
+		branches = append(branches, LocalBranch{
+			Name:     fields[0],
+			Upstream: fields[1],
+			Gone:     fields[2] == "[gone]",
+			Hash:     fields[3],
+		})
+	}
+	return branches, nil
+}
+
+// ListBranchesMergedInto returns the local branches whose commits are all in the ref.
+func (g *Git) ListBranchesMergedInto(ref string) (map[string]bool, error) {
+	output, err := g.RunGitWithStdout("for-each-ref", "--format=%(refname:short)", "--merged", ref, "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string]bool{}
+	for _, b := range strings.Split(output, "\n") {
+		if b != "" {
+			merged[b] = true
+		}
+	}
+	return merged, nil
+}
+
+// BranchExists returns true if the local branch exists.
+func (g *Git) BranchExists(name string) bool {
+	_, err := g.RunGitWithStdout("rev-parse", "--verify", "-q", "refs/heads/"+name)
+	return err == nil
+}
+
+// TagExists returns true if the tag exists.
+func (g *Git) TagExists(name string) bool {
+	_, err := g.RunGitWithStdout("rev-parse", "--verify", "-q", "refs/tags/"+name)
+	return err == nil
+}
+
+// DeleteBranch force deletes the local branch since squash merged branches are not seen as merged by git.
+func (g *Git) DeleteBranch(name string) error {
+	return g.RunGit("branch", "-D", name)
+}
+
+// DeleteMergedBranch deletes the local branch only if it is merged in its upstream, or HEAD if it has none.
+func (g *Git) DeleteMergedBranch(name string) error {
+	return g.RunGit("branch", "-d", name)
+}
+
+func (g *Git) DeleteRemoteBranch(remote, name string) error {
+	return g.RunGit("push", remote, "--delete", name)
+}
+
+func (g *Git) FetchAndPrune() error {
+	return g.RunGit("fetch", "--prune")
 }
 
 func (g *Git) getBranches() []string {
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	upstreamRemote  = "upstream"
+	defaultCloneURL = "git@github.com:{{ .Organization }}/{{ .Repository }}.git"
+)
+
+// e.g. git@github.com:owner/repo.git
+var scpLikeURLRegex = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+type Remote struct {
+	Name, URL, Host, Owner, Repository string
+}
+
+// ParseRemoteURL extracts the host, owner and repository of SSH (scp-like or ssh://), HTTPS and git URLs.
+func ParseRemoteURL(rawURL string) (Remote, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	remote := Remote{URL: rawURL}
+	var repoPath string
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return remote, errors.Wrapf(err, "failed to parse remote '%v'", rawURL)
+		}
+		remote.Host = u.Hostname()
+		repoPath = u.Path
+	} else if groups := scpLikeURLRegex.FindStringSubmatch(rawURL); groups != nil {
+		remote.Host = groups[1]
+		repoPath = groups[2]
+	} else {
+		return remote, errors.Errorf("unsupported remote '%v'", rawURL)
+	}
+	elements := strings.Split(strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git"), "/")
+	if len(elements) < 2 || elements[len(elements)-1] == "" {
+		return remote, errors.Errorf("no owner and repository found in remote '%v'", rawURL)
+	}
+	// GitLab like hosts support nested groups, e.g. group/subgroup/repo.
+	remote.Owner = strings.Join(elements[:len(elements)-1], "/")
+	remote.Repository = elements[len(elements)-1]
+	return remote, nil
+}
+
+func (g *Git) ListRemotes() ([]string, error) {
+	output, err := g.RunGitWithStdout("remote")
+	if err != nil {
+		return nil, err
+	}
+	var remotes []string
+	for _, r := range strings.Split(output, "\n") {
+		if r = strings.TrimSpace(r); r != "" {
+			remotes = append(remotes, r)
+		}
+	}
+	return remotes, nil
+}
+
+func (g *Git) GetRemote(name string) (Remote, error) {
+	remoteURL, err := g.RunGitWithStdout("config", "--get", "remote."+name+".url")
+	if err != nil {
+		return Remote{}, errors.Wrapf(err, "remote '%v' not found", name)
+	}
+	remote, err := ParseRemoteURL(remoteURL)
+	remote.Name = name
+	return remote, err
+}
+
+// GetUpstreamRemoteName returns the remote of the canonical repository: upstream when working on a fork, origin
+// otherwise.
+func (g *Git) GetUpstreamRemoteName() string {
+	if g.upstreamRemote != "" {
+		return g.upstreamRemote
+	}
+	g.upstreamRemote = defaultRemote
+	remotes, _ := g.ListRemotes()
+	for _, r := range remotes {
+		if r == upstreamRemote {
+			g.upstreamRemote = upstreamRemote
+		}
+	}
+	return g.upstreamRemote
+}
+
+func (g *Git) GetUpstreamRemote() (Remote, error) {
+	return g.GetRemote(g.GetUpstreamRemoteName())
+}
+
+// GetPushRemoteName returns the remote the branches are pushed to, origin unless configured otherwise.
+func (g *Git) GetPushRemoteName(cfg *Configuration) string {
+	if cfg != nil && cfg.Git.PushRemote != "" {
+		return cfg.Git.PushRemote
+	}
+	return defaultRemote
+}
+
+// GetRemoteBranchHash returns the hash of the remote-tracking branch, or an empty string if it was never fetched.
+func (g *Git) GetRemoteBranchHash(remote, branch string) string {
+	hash, err := g.RunGitWithStdout("rev-parse", "--verify", "-q", "refs/remotes/"+remote+"/"+branch)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(hash)
+}
+
+// CloneURL renders the clone URL template of the configuration for the repository.
+func CloneURL(cfg *Configuration, repository string) (string, error) {
+	cloneURL := cfg.Git.CloneURL
+	if cloneURL == "" {
+		cloneURL = defaultCloneURL
+	}
+	tmpl, err := template.New("cloneUrl").Parse(cloneURL)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse git.cloneUrl")
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]string{"Organization": cfg.GitHub.Organization, "Repository": repository})
+	return buf.String(), err
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRemoteURL(t *testing.T) {
+	t.Parallel()
+	for _, rawURL := range []string{
+		"git@github.com:nestoca/nub.git",
+		"git@github.com:nestoca/nub",
+		"ssh://git@github.com/nestoca/nub.git",
+		"ssh://git@github.com:22/nestoca/nub.git",
+		"https://github.com/nestoca/nub.git",
+		"https://user@github.com/nestoca/nub/",
+	} {
+		remote, err := ParseRemoteURL(rawURL)
+		assert.Nil(t, err, rawURL)
+		assert.Equal(t, "github.com", remote.Host, rawURL)
+		assert.Equal(t, "nestoca", remote.Owner, rawURL)
+		assert.Equal(t, "nub", remote.Repository, rawURL)
+	}
+
+	remote, err := ParseRemoteURL("https://gitlab.com/group/subgroup/repo.git")
+	assert.Nil(t, err)
+	assert.Equal(t, "group/subgroup", remote.Owner)
+
+	_, err = ParseRemoteURL("/tmp/repo")
+	assert.NotNil(t, err)
+}
+
+func TestCloneURL(t *testing.T) {
+	t.Parallel()
+	cfg := &Configuration{}
+	cfg.GitHub.Organization = "nestoca"
+	cloneURL, err := CloneURL(cfg, "nub")
+	assert.Nil(t, err)
+	assert.Equal(t, "git@github.com:nestoca/nub.git", cloneURL)
+
+	cfg.Git.CloneURL = "https://git.example.com/{{ .Organization }}/{{ .Repository }}"
+	cloneURL, err = CloneURL(cfg, "nub")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://git.example.com/nestoca/nub", cloneURL)
+}
+
+func TestUpstreamRemote(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepository(t)
+	defer os.RemoveAll(dir)
+
+	g := MustInitGit(dir)
+	assert.Nil(t, g.RunGit("remote", "add", "origin", "git@github.com:someone/nub.git"))
+	assert.Equal(t, "origin", g.GetUpstreamRemoteName())
+
+	g = MustInitGit(dir)
+	assert.Nil(t, g.RunGit("remote", "add", "upstream", "https://github.com/nestoca/nub.git"))
+	assert.Equal(t, "upstream", g.GetUpstreamRemoteName())
+	owner, err := g.GetRepositoryOwner()
+	assert.Nil(t, err)
+	assert.Equal(t, "nestoca", owner)
+	name, err := g.GetRepositoryName()
+	assert.Nil(t, err)
+	assert.Equal(t, "nub", name)
+}
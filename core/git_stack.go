@@ -0,0 +1,161 @@
+package core
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The parent of a stacked branch is stored in the git config of the repository, e.g. branch.<name>.nubParent. The
+// commit of the parent the branch is based on is stored with it, e.g. branch.<name>.nubParentBase, so that the branch
+// can be moved once its parent is squash merged and deleted.
+const (
+	stackParentKey     = "nubParent"
+	stackParentBaseKey = "nubParentBase"
+)
+
+func stackParentConfig(branch string) string {
+	return "branch." + branch + "." + stackParentKey
+}
+
+func stackParentBaseConfig(branch string) string {
+	return "branch." + branch + "." + stackParentBaseKey
+}
+
+// CreateStackedBranch creates the branch on top of the current feature branch and records it as its parent.
+func (g *Git) CreateStackedBranch(name string) error {
+	parent := g.GetCurrentBranch()
+	if parent == "" || parent == g.GetDefaultBranch() {
+		return errors.New("stacked branches are created on top of a feature branch, checkout one first")
+	}
+	name = g.sanitizeBranchName(name)
+	if err := g.RunGit("checkout", "-b", name); err != nil {
+		return err
+	}
+	if err := g.SetParentBranch(name, parent); err != nil {
+		return err
+	}
+	return g.SetParentBase(name, parent)
+}
+
+// GetParentBranch returns the branch the stacked branch is based on, empty if it is not stacked.
+func (g *Git) GetParentBranch(branch string) string {
+	parent, err := g.RunGitWithStdout("config", "--get", stackParentConfig(branch))
+	if err != nil {
+		return ""
+	}
+	return parent
+}
+
+// GetBaseBranch returns the branch the PRs of the branch should target: its parent or the default branch.
+func (g *Git) GetBaseBranch(branch string) string {
+	if parent := g.GetParentBranch(branch); parent != "" {
+		return parent
+	}
+	return g.GetDefaultBranch()
+}
+
+// SetParentBranch records the parent of the branch, the branch is unstacked if the parent is the default branch.
+func (g *Git) SetParentBranch(branch, parent string) error {
+	if parent == "" || parent == g.GetDefaultBranch() {
+		if g.GetParentBranch(branch) == "" {
+			return nil
+		}
+		return g.RunGit("config", "--unset", stackParentConfig(branch))
+	}
+	return g.RunGit("config", stackParentConfig(branch), parent)
+}
+
+// GetParentBase returns the commit of the parent the branch is based on, empty if it was not recorded or if the
+// branch was rebased onto something else since.
+func (g *Git) GetParentBase(branch string) string {
+	base, err := g.RunGitWithStdout("config", "--get", stackParentBaseConfig(branch))
+	if err != nil || !g.IsAncestor(base, branch) {
+		return ""
+	}
+	return base
+}
+
+// SetParentBase records the commit the reference points to as the base of the branch.
+func (g *Git) SetParentBase(branch, ref string) error {
+	base, err := g.RunGitWithStdout("rev-parse", "--verify", "-q", ref+"^{commit}")
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve '%v'", ref)
+	}
+	return g.RunGit("config", stackParentBaseConfig(branch), base)
+}
+
+// IsAncestor returns true if the commit is reachable from the reference.
+func (g *Git) IsAncestor(commit, ref string) bool {
+	return commit != "" && g.RunGit("merge-base", "--is-ancestor", commit, ref) == nil
+}
+
+// listParentBranches maps the stacked branches to their parent.
+func (g *Git) listParentBranches() (map[string]string, error) {
+	parents := map[string]string{}
+	output, err := g.RunGitWithStdout("config", "--get-regexp", `^branch\..*\.`+strings.ToLower(stackParentKey)+`$`)
+	if err != nil {
+		// git config exits with 1 when nothing matches.
+		return parents, nil
+	}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		branch := strings.TrimSuffix(strings.TrimPrefix(fields[0], "branch."), "."+strings.ToLower(stackParentKey))
+		parents[branch] = fields[1]
+	}
+	return parents, nil
+}
+
+// GetStack returns the branches of the stack the branch belongs to, every parent before its children.
+func (g *Git) GetStack(branch string) ([]string, error) {
+	parents, err := g.listParentBranches()
+	if err != nil {
+		return nil, err
+	}
+	branches, err := g.ListLocalBranches()
+	if err != nil {
+		return nil, err
+	}
+	local := map[string]bool{}
+	for _, b := range branches {
+		local[b.Name] = true
+	}
+	root := branch
+	visited := map[string]bool{root: true}
+	for parent := parents[root]; local[parent] && !visited[parent] && parent != g.GetDefaultBranch(); parent = parents[root] {
+		root = parent
+		visited[root] = true
+	}
+	children := map[string][]string{}
+	for child, parent := range parents {
+		if local[child] {
+			children[parent] = append(children[parent], child)
+		}
+	}
+	stack := []string{root}
+	visited = map[string]bool{root: true}
+	for i := 0; i < len(stack); i++ {
+		next := children[stack[i]]
+		sort.Strings(next)
+		for _, child := range next {
+			if !visited[child] {
+				visited[child] = true
+				stack = append(stack, child)
+			}
+		}
+	}
+	return stack, nil
+}
+
+// RebaseOnto replays the commits of the branch made after oldBase onto newBase. If oldBase is empty, the commits not
+// in newBase are replayed.
+func (g *Git) RebaseOnto(newBase, oldBase, branch string) error {
+	if oldBase == "" {
+		return g.RunGit("rebase", newBase, branch)
+	}
+	return g.RunGit("rebase", "--onto", newBase, oldBase, branch)
+}
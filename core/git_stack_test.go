@@ -0,0 +1,63 @@
+package core
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStackedBranches(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepository(t)
+	defer os.RemoveAll(dir)
+	g := MustInitGit(dir)
+	commit := func(file string) {
+		commitTestFile(t, g, file, file)
+	}
+
+	assert.NotNil(t, g.CreateStackedBranch("on-default"), "stacked branches need a feature branch")
+
+	assert.Nil(t, g.RunGit("checkout", "-q", "-b", "feature"))
+	commit("feature.txt")
+	g = MustInitGit(dir)
+	assert.Nil(t, g.CreateStackedBranch("child"))
+	commit("child.txt")
+	g = MustInitGit(dir)
+	assert.Nil(t, g.CreateStackedBranch("grandchild"))
+	commit("grandchild.txt")
+
+	assert.Equal(t, "", g.GetParentBranch("feature"))
+	assert.Equal(t, "master", g.GetBaseBranch("feature"))
+	assert.Equal(t, "feature", g.GetBaseBranch("child"))
+	assert.Equal(t, "child", g.GetBaseBranch("grandchild"))
+
+	stack, err := g.GetStack("grandchild")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"feature", "child", "grandchild"}, stack)
+	stack, err = g.GetStack("feature")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"feature", "child", "grandchild"}, stack)
+
+	featureTip, err := g.RunGitWithStdout("rev-parse", "feature")
+	assert.Nil(t, err)
+	assert.Equal(t, featureTip, g.GetParentBase("child"))
+
+	// squash merge of feature into master and deletion of feature, child is moved onto master.
+	assert.Nil(t, g.RunGit("checkout", "-q", "master"))
+	commitTestFile(t, g, "feature.txt", "squashed")
+	assert.Nil(t, g.RunGit("branch", "-q", "-D", "feature"))
+	assert.Nil(t, g.SetParentBranch("child", "master"))
+	assert.Equal(t, "", g.GetParentBranch("child"))
+	assert.Nil(t, g.RebaseOnto("master", g.GetParentBase("child"), "child"))
+	assert.True(t, g.IsAncestor("master", "child"))
+	count, err := g.RunGitWithStdout("rev-list", "--count", "master..child")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", count)
+
+	assert.Equal(t, "", g.GetParentBase("child"), "the recorded base is ignored once the branch is rebased")
+	assert.Nil(t, g.SetParentBase("child", "master"))
+	masterTip, err := g.RunGitWithStdout("rev-parse", "master")
+	assert.Nil(t, err)
+	assert.Equal(t, masterTip, g.GetParentBase("child"))
+}
@@ -1,7 +1,13 @@
 package core
 
-import "testing"
-import "github.com/stretchr/testify/assert"
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestSanitizeBranchName(t *testing.T) {
 	t.Parallel()
@@ -13,3 +19,201 @@ func TestExtractIssueKey(t *testing.T) {
 	t.Parallel()
 	assert.Equal(t, "PL-2345", InitGit().extractIssueKeyFromName("PL-2345-asfsd-asfsf-sffff"))
 }
+
+// initTestRepository creates a repository with an empty commit on master. The committer is configured in the
+// repository since the rebases and merges need one.
+func initTestRepository(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "nub-git")
+	assert.Nil(t, err)
+	g := MustInitGit(dir)
+	assert.Nil(t, g.RunGit("init", "-q"))
+	assert.Nil(t, g.RunGit("symbolic-ref", "HEAD", "refs/heads/master"))
+	assert.Nil(t, g.RunGit("config", "user.name", "test"))
+	assert.Nil(t, g.RunGit("config", "user.email", "test@example.com"))
+	assert.Nil(t, g.RunGit("commit", "-q", "--allow-empty", "-m", "init"))
+	return dir
+}
+
+// commitTestFile writes the file in the repository and commits it, the message is the name of the file.
+func commitTestFile(t *testing.T, g *Git, file, content string) {
+	assert.Nil(t, ioutil.WriteFile(path.Join(g.dir, file), []byte(content), 0644))
+	assert.Nil(t, g.RunGit("add", file))
+	assert.Nil(t, g.RunGit("commit", "-q", "-m", file))
+}
+
+func TestGetDefaultBranch(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepository(t)
+	defer os.RemoveAll(dir)
+
+	assert.Equal(t, "master", MustInitGit(dir).GetDefaultBranch())
+
+	g := MustInitGit(dir)
+	assert.Nil(t, g.RunGit("update-ref", "refs/remotes/origin/main", "HEAD"))
+	assert.Nil(t, g.RunGit("symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/main"))
+	assert.Equal(t, "main", g.GetDefaultBranch())
+	assert.Equal(t, "origin/main", g.GetDefaultBranchRef())
+
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, manifestFile), []byte("name: test\ndefaultBranch: develop\n"), 0644))
+	assert.Equal(t, "develop", MustInitGit(dir).GetDefaultBranch())
+}
+
+func TestStagedDiffAndDiscardBranch(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepository(t)
+	defer os.RemoveAll(dir)
+
+	g := MustInitGit(dir)
+	assert.Nil(t, g.RunGit("remote", "add", "origin", dir))
+	assert.Nil(t, g.RunGit("checkout", "-q", "-b", "original"))
+	assert.Nil(t, g.CreateBranchFromDefault("change", false))
+	assert.Equal(t, "change", g.GetCurrentBranch())
+	assert.NotNil(t, g.CreateBranchFromDefault("change", false), "the branch already exists")
+	assert.Nil(t, g.RunGit("commit", "-q", "--allow-empty", "-m", "existing"))
+	assert.Nil(t, g.CreateBranchFromDefault("change", true))
+	assert.Nil(t, g.RunGit("diff", "--quiet", "change", "master"), "the forced branch is reset")
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, "new.txt"), []byte("hello\n"), 0644))
+	assert.True(t, g.ContainedUncommittedChanges())
+
+	diff, err := g.StagedDiff()
+	assert.Nil(t, err)
+	assert.Contains(t, diff, "+hello")
+
+	assert.Nil(t, g.DiscardBranch("change", "original"))
+	assert.False(t, g.ContainedUncommittedChanges())
+	current, err := g.RunGitWithStdout("symbolic-ref", "--short", "HEAD")
+	assert.Nil(t, err)
+	assert.Equal(t, "original", current)
+	branches, err := g.RunGitWithStdout("branch", "--list", "change")
+	assert.Nil(t, err)
+	assert.Empty(t, branches)
+}
+
+func TestSafeSync(t *testing.T) {
+	t.Parallel()
+	upstream := initTestRepository(t)
+	defer os.RemoveAll(upstream)
+	dir, err := ioutil.TempDir("", "nub-clone")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	clone := path.Join(dir, "clone")
+	assert.Nil(t, InitGit().RunGit("clone", "-q", upstream, clone))
+
+	assert.Nil(t, MustInitGit(upstream).RunGit("commit", "-q", "--allow-empty", "-m", "upstream change"))
+
+	g := MustInitGit(clone)
+	assert.Nil(t, g.RunGit("checkout", "-q", "-b", "feature"))
+	assert.Nil(t, ioutil.WriteFile(path.Join(clone, "untracked.txt"), []byte("keep me\n"), 0644))
+
+	_, err = MustInitGit(clone).SafeSync(false)
+	assert.NotNil(t, err)
+
+	output, err := MustInitGit(clone).SafeSync(true)
+	assert.Nil(t, err, output)
+	assert.Equal(t, "feature", MustInitGit(clone).GetCurrentBranch())
+	content, err := ioutil.ReadFile(path.Join(clone, "untracked.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "keep me\n", string(content))
+	subject, err := g.RunGitWithStdout("log", "-1", "--pretty=%s", "master")
+	assert.Nil(t, err)
+	assert.Equal(t, "upstream change", subject)
+}
+
+func TestListLocalBranches(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepository(t)
+	defer os.RemoveAll(dir)
+	g := MustInitGit(dir)
+	assert.Nil(t, g.RunGit("branch", "merged"))
+	assert.Nil(t, g.RunGit("branch", "gone"))
+	assert.Nil(t, g.RunGit("remote", "add", "origin", dir))
+	assert.Nil(t, g.RunGit("update-ref", "refs/remotes/origin/gone", "HEAD"))
+	assert.Nil(t, g.RunGit("branch", "--set-upstream-to=origin/gone", "gone"))
+	assert.Nil(t, g.RunGit("update-ref", "-d", "refs/remotes/origin/gone"))
+	assert.Nil(t, g.RunGit("checkout", "-q", "-b", "pending"))
+	assert.Nil(t, g.RunGit("commit", "-q", "--allow-empty", "-m", "pending"))
+
+	branches, err := g.ListLocalBranches()
+	assert.Nil(t, err)
+	gone := map[string]bool{}
+	for _, b := range branches {
+		gone[b.Name] = b.Gone
+	}
+	assert.Equal(t, map[string]bool{"gone": true, "master": false, "merged": false, "pending": false}, gone)
+
+	merged, err := g.ListBranchesMergedInto("master")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"gone": true, "master": true, "merged": true}, merged)
+
+	assert.Nil(t, g.DeleteBranch("merged"))
+	branches, err = g.ListLocalBranches()
+	assert.Nil(t, err)
+	assert.Len(t, branches, 3)
+}
+
+func TestForcePushWithLease(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepository(t)
+	defer os.RemoveAll(dir)
+	remotes, err := ioutil.TempDir("", "nub-remote")
+	assert.Nil(t, err)
+	defer os.RemoveAll(remotes)
+	remote, other := path.Join(remotes, "remote"), path.Join(remotes, "other")
+	g := MustInitGit(dir)
+	assert.Nil(t, g.RunGit("clone", "-q", "--bare", dir, remote))
+	assert.Nil(t, g.RunGit("remote", "add", "origin", remote))
+	assert.Nil(t, g.RunGit("checkout", "-q", "-b", "feature"))
+	commitTestFile(t, g, "feature.txt", "feature")
+	assert.Nil(t, g.RunGit("push", "-q", "origin", "feature"))
+
+	// someone else pushes to the branch in the meantime.
+	assert.Nil(t, g.RunGit("clone", "-q", "-b", "feature", remote, other))
+	o := MustInitGit(other)
+	assert.Nil(t, o.RunGit("config", "user.name", "other"))
+	assert.Nil(t, o.RunGit("config", "user.email", "other@example.com"))
+	commitTestFile(t, o, "other.txt", "other")
+	assert.Nil(t, o.RunGit("push", "-q", "origin", "feature"))
+
+	lease := g.GetRemoteBranchHash("origin", "feature")
+	assert.NotEmpty(t, lease)
+	assert.Nil(t, g.RunGit("fetch", "-q", "origin"))
+	assert.Nil(t, g.RunGit("commit", "-q", "--amend", "-m", "rewritten"))
+	assert.NotNil(t, g.ForcePushWithLease(&Configuration{}, "feature", lease), "the remote branch diverged")
+
+	assert.Nil(t, g.ForcePushWithLease(&Configuration{}, "feature", g.GetRemoteBranchHash("origin", "feature")))
+	pushed, err := MustInitGit(remote).RunGitWithStdout("log", "-1", "--pretty=%s", "feature")
+	assert.Nil(t, err)
+	assert.Equal(t, "rewritten", pushed)
+}
+
+func TestUpdateBranch(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepository(t)
+	defer os.RemoveAll(dir)
+	g := MustInitGit(dir)
+	assert.Nil(t, g.RunGit("remote", "add", "origin", dir))
+	assert.Nil(t, g.RunGit("checkout", "-q", "-b", "feature"))
+	commitTestFile(t, g, "conflict.txt", "feature")
+	assert.Nil(t, g.RunGit("checkout", "-q", "master"))
+	commitTestFile(t, g, "other.txt", "master")
+
+	_, err := g.UpdateBranch(UpdateStrategyRebase)
+	assert.NotNil(t, err, "the default branch cannot be updated")
+
+	g = MustInitGit(dir)
+	assert.Nil(t, g.RunGit("checkout", "-q", "feature"))
+	conflicts, err := g.UpdateBranch(UpdateStrategyRebase)
+	assert.Nil(t, err)
+	assert.Empty(t, conflicts)
+	assert.Nil(t, g.RunGit("merge-base", "--is-ancestor", "master", "feature"))
+
+	assert.Nil(t, g.RunGit("checkout", "-q", "master"))
+	commitTestFile(t, g, "conflict.txt", "master")
+	g = MustInitGit(dir)
+	assert.Nil(t, g.RunGit("checkout", "-q", "feature"))
+	conflicts, err = g.UpdateBranch(UpdateStrategyMerge)
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"conflict.txt"}, conflicts)
+	assert.Nil(t, g.AbortUpdateBranch(UpdateStrategyMerge))
+	assert.False(t, g.ContainedUncommittedChanges())
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/j-martin/nub/utils"
+	"github.com/pkg/errors"
+)
+
+// hookMarker identifies the hooks installed by nub, the other hooks are not overwritten unless forced.
+const hookMarker = "# Installed by 'nub hooks install'."
+
+// Hooks are the git hooks delegating to 'nub hooks <hook>'.
+var Hooks = []string{"commit-msg", "prepare-commit-msg", "pre-push"}
+
+func hookScript(hook string) string {
+	return "#!/bin/sh\n" + hookMarker + "\nexec nub hooks " + hook + " \"$@\"\n"
+}
+
+// getHooksDir returns the hooks directory of the repository, core.hooksPath is honoured.
+func (g *Git) getHooksDir() (string, error) {
+	dir, err := g.RunGitWithStdout("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(dir) {
+		dir = path.Join(g.dir, dir)
+	}
+	return dir, nil
+}
+
+// InstallHooks writes the hooks delegating to nub. The existing hooks not installed by nub are kept, unless forced in
+// which case they are backed up with the .bak extension.
+func (g *Git) InstallHooks(force bool) error {
+	dir, err := g.getHooksDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, hook := range Hooks {
+		hookPath := path.Join(dir, hook)
+		existing, err := ioutil.ReadFile(hookPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil && !strings.Contains(string(existing), hookMarker) {
+			if !force {
+				log.Printf("%v already exists and was not installed by nub. Skipping, use --force to replace it.", hookPath)
+				continue
+			}
+			log.Printf("Backing up %v to %v.bak", hookPath, hookPath)
+			if err := utils.Copy(hookPath, hookPath+".bak"); err != nil {
+				return err
+			}
+		}
+		log.Printf("Installing %v", hookPath)
+		if err := ioutil.WriteFile(hookPath, []byte(hookScript(hook)), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrepareCommitMessage pre-fills the header of the message from the branch, e.g. 'feat(ABC-123): add the thing'. The
+// messages passed with -m, merges, squashes and amends are left as is, so are the branches without issue key, e.g.
+// the default branch.
+func (g *Git) PrepareCommitMessage(file, source string) error {
+	if source != "" && source != "template" {
+		return nil
+	}
+	if g.GetIssueKeyFromBranch() == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == scissorsLine {
+			// the diff added by 'git commit --verbose' follows.
+			break
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			// the message is already written, e.g. by a template.
+			return nil
+		}
+	}
+	m := g.NewCommitMessage("")
+	if m.Subject == "" {
+		return nil
+	}
+	return ioutil.WriteFile(file, []byte(m.Header()+"\n"+string(data)), 0644)
+}
+
+// CheckPush reads the refs pushed, as passed to the pre-push hook, and refuses the pushes to the default branch. If
+// the issue key is required, the branches without one are refused too.
+func (g *Git) CheckPush(refs io.Reader, requireIssueKey bool) error {
+	scanner := bufio.NewScanner(refs)
+	for scanner.Scan() {
+		// <local ref> <local sha> <remote ref> <remote sha>
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 || strings.Trim(fields[1], "0") == "" {
+			// deletions are allowed.
+			continue
+		}
+		if !strings.HasPrefix(fields[2], "refs/heads/") {
+			continue
+		}
+		branch := strings.TrimPrefix(fields[2], "refs/heads/")
+		if branch == g.GetDefaultBranch() {
+			return errors.Errorf("pushing to the default branch '%v' is not allowed, open a PR instead", branch)
+		}
+		if requireIssueKey && g.extractIssueKeyFromName(branch) == "" {
+			return errors.Errorf("the branch '%v' has no issue key, e.g. feat/ABC-123/description", branch)
+		}
+	}
+	return scanner.Err()
+}
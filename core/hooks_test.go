@@ -0,0 +1,94 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallHooks(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepository(t)
+	defer os.RemoveAll(dir)
+	g := MustInitGit(dir)
+	hooksDir := path.Join(dir, ".git", "hooks")
+	assert.Nil(t, os.MkdirAll(hooksDir, 0755))
+	assert.Nil(t, ioutil.WriteFile(path.Join(hooksDir, "pre-push"), []byte("#!/bin/sh\nexit 0\n"), 0755))
+
+	assert.Nil(t, g.InstallHooks(false))
+	commitMsg, err := ioutil.ReadFile(path.Join(hooksDir, "commit-msg"))
+	assert.Nil(t, err)
+	assert.Equal(t, hookScript("commit-msg"), string(commitMsg))
+	prePush, err := ioutil.ReadFile(path.Join(hooksDir, "pre-push"))
+	assert.Nil(t, err)
+	assert.Equal(t, "#!/bin/sh\nexit 0\n", string(prePush))
+
+	assert.Nil(t, g.InstallHooks(true))
+	prePush, err = ioutil.ReadFile(path.Join(hooksDir, "pre-push"))
+	assert.Nil(t, err)
+	assert.Equal(t, hookScript("pre-push"), string(prePush))
+	backup, err := ioutil.ReadFile(path.Join(hooksDir, "pre-push.bak"))
+	assert.Nil(t, err)
+	assert.Equal(t, "#!/bin/sh\nexit 0\n", string(backup))
+}
+
+func TestPrepareCommitMessage(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepository(t)
+	defer os.RemoveAll(dir)
+	g := MustInitGit(dir)
+	assert.Nil(t, g.RunGit("checkout", "-q", "-b", "feat/ABC-123/add-the-thing"))
+	file := path.Join(dir, "COMMIT_EDITMSG")
+	comments := "\n# Please enter the commit message\n"
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte(comments), 0644))
+	assert.Nil(t, g.PrepareCommitMessage(file, ""))
+	data, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "feat(ABC-123): add the thing\n"+comments, string(data))
+
+	verbose := comments + scissorsLine + "\ndiff --git a/thing.txt b/thing.txt\n+thing\n"
+	assert.Nil(t, ioutil.WriteFile(file, []byte(verbose), 0644))
+	assert.Nil(t, g.PrepareCommitMessage(file, ""))
+	data, err = ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "feat(ABC-123): add the thing\n"+verbose, string(data))
+
+	for _, branch := range []string{"master", "some-branch"} {
+		g = MustInitGit(dir)
+		assert.Nil(t, g.RunGit("checkout", "-q", "-B", branch))
+		assert.Nil(t, ioutil.WriteFile(file, []byte(comments), 0644))
+		assert.Nil(t, g.PrepareCommitMessage(file, ""))
+		data, err = ioutil.ReadFile(file)
+		assert.Nil(t, err)
+		assert.Equal(t, comments, string(data), "the branches without issue key are not pre-filled")
+	}
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte("fix: passed with -m\n"), 0644))
+	assert.Nil(t, g.PrepareCommitMessage(file, "message"))
+	data, err = ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "fix: passed with -m\n", string(data))
+}
+
+func TestCheckPush(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepository(t)
+	defer os.RemoveAll(dir)
+	g := MustInitGit(dir)
+	sha := strings.Repeat("a", 40)
+	zero := strings.Repeat("0", 40)
+	push := func(branch string) string {
+		return "refs/heads/" + branch + " " + sha + " refs/heads/" + branch + " " + zero + "\n"
+	}
+
+	assert.Nil(t, g.CheckPush(strings.NewReader(push("feat/ABC-1/thing")), true))
+	assert.Nil(t, g.CheckPush(strings.NewReader(push("thing")), false))
+	assert.NotNil(t, g.CheckPush(strings.NewReader(push("thing")), true))
+	assert.NotNil(t, g.CheckPush(strings.NewReader(push("master")), false))
+	assert.Nil(t, g.CheckPush(strings.NewReader("(delete) "+zero+" refs/heads/master "+sha+"\n"), false))
+}
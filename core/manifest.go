@@ -8,27 +8,37 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
-const manifestFile = ".bench.yml"
+const (
+	manifestFile       = ".bench.yml"
+	legacyManifestFile = "manifest.yml"
+)
 
 type Ownership map[string][]User
 
 type Manifest struct {
-	Name          string
-	Active        bool
-	Repository    string
-	LastUpdate    int64
-	Platform      string // what is it running on
-	Platforms     []string
-	Language      string
-	Languages     []string
-	Types         []string
-	Dependencies  []Dependency
-	Protocols     []Protocol
-	Version       string
-	Branch        string
+	Name         string
+	Active       bool
+	Repository   string
+	LastUpdate   int64
+	Platform     string // what is it running on
+	Platforms    []string
+	Language     string
+	Languages    []string
+	Types        []string
+	Dependencies []Dependency
+	Protocols    []Protocol
+	Version      string
+	Branch       string
+	// optional, overrides the default branch of the remote, e.g. main.
+	DefaultBranch string `yaml:"defaultBranch,omitempty"`
 	Deploy        Deploy
 	Documentation Documentation
 	Readme        string
@@ -67,6 +77,10 @@ type Deploy struct {
 type Documentation struct {
 	PageId      string   `yaml:"pageId"`
 	IgnoredDirs []string `yaml:"ignoredDirs"`
+	// optional, page under which the page is created, overrides the one in the config.
+	ParentPageId string `yaml:"parentPageId,omitempty"`
+	// optional, path to the template of the Confluence page header, overrides the one in the config.
+	Template string `yaml:"template,omitempty"`
 }
 
 type Protocol struct {
@@ -89,17 +103,28 @@ func (e Manifests) Swap(i, j int) {
 }
 
 func LoadManifest() (*Manifest, error) {
+	return LoadRepositoryManifest("")
+}
+
+func HasManifest(repoDir string) bool {
+	for _, f := range []string{manifestFile, legacyManifestFile} {
+		if exists, _ := utils.PathExists(repoDir, f); exists {
+			return true
+		}
+	}
+	return false
+}
+
+func LoadRepositoryManifest(repoDir string) (*Manifest, error) {
 	m := &Manifest{}
+	g := MustInitGit(repoDir)
 
-	if !utils.InRepository() {
+	if !utils.IsRepository(g.dir) {
 		return m, errors.New("must be executed in a repository")
 	}
 
-	data, err := ioutil.ReadFile(manifestFile)
-	if err != nil {
-		data, err = ioutil.ReadFile("manifest.yml")
-	}
-	err = yaml.Unmarshal(data, m)
+	data, _ := ioutil.ReadFile(manifestPath(g.dir))
+	err := yaml.Unmarshal(data, m)
 
 	if len(m.Languages) == 0 && m.Language != "" {
 		m.Languages = []string{m.Language}
@@ -126,18 +151,121 @@ func LoadManifest() (*Manifest, error) {
 	}
 
 	m.LastUpdate = time.Now().Unix()
-	m.Repository = InitGit().GetCurrentRepositoryName()
-	m.Branch = InitGit().GetCurrentBranch()
+	m.Repository, _ = g.GetRepositoryName()
+	if m.Repository == "" {
+		absDir, _ := filepath.Abs(g.dir)
+		m.Repository = path.Base(absDir)
+	}
+	m.Branch = g.GetCurrentBranch()
+	if m.DefaultBranch == "" {
+		m.DefaultBranch = g.GetDefaultBranch()
+	}
 
-	readme, _ := ioutil.ReadFile("README.md")
+	readme, _ := ioutil.ReadFile(path.Join(g.dir, "README.md"))
 	m.Readme = string(readme)
 
-	changelog, _ := ioutil.ReadFile("CHANGELOG.md")
+	changelog, _ := ioutil.ReadFile(path.Join(g.dir, "CHANGELOG.md"))
 	m.ChangeLog = string(changelog)
 
 	return m, err
 }
 
+func manifestPath(repoDir string) string {
+	if exists, _ := utils.PathExists(repoDir, manifestFile); !exists {
+		if exists, _ := utils.PathExists(repoDir, legacyManifestFile); exists {
+			return path.Join(repoDir, legacyManifestFile)
+		}
+	}
+	return path.Join(repoDir, manifestFile)
+}
+
+// readManifestDefaultBranch returns the default branch defined in the manifest, if any. The manifest is read as is
+// since loading it requires the default branch.
+func readManifestDefaultBranch(repoDir string) string {
+	data, err := ioutil.ReadFile(manifestPath(repoDir))
+	if err != nil {
+		return ""
+	}
+	m := &Manifest{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return ""
+	}
+	return m.DefaultBranch
+}
+
+// SetManifestPageId writes the Confluence page id in the manifest without reformatting the rest of the file.
+func SetManifestPageId(repoDir, pageID string) error {
+	filePath := manifestPath(MustInitGit(repoDir).dir)
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	updated, err := setManifestValue(data, "documentation", "pageId", strconv.Quote(pageID))
+	if err != nil {
+		return errors.New(filePath + ": " + err.Error())
+	}
+	return ioutil.WriteFile(filePath, updated, 0644)
+}
+
+// setManifestValue sets the value of the key under the parent mapping, the rest of the file is kept as is, comments
+// included. Only block mappings are supported, e.g. not 'parent: {key: value}'.
+func setManifestValue(data []byte, parent, key, value string) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+	index := indexManifestLines(data)
+	if lineNumber, ok := index[parent+"."+key]; ok {
+		line := lines[lineNumber-1]
+		pos := strings.Index(line, key)
+		_, comment := splitYAMLComment(line[pos+len(key):])
+		lines[lineNumber-1] = line[:pos] + key + ": " + value + comment
+		return []byte(strings.Join(lines, "\n")), nil
+	}
+	if lineNumber, ok := index[parent]; ok {
+		parentLine := lines[lineNumber-1]
+		if _, parentValue, _ := splitYAMLKey(strings.TrimSpace(parentLine)); parentValue != "" {
+			if inline, _ := splitYAMLComment(" " + parentValue); strings.TrimSpace(inline) != "" {
+				return nil, errors.New("'" + parent + "' is not a block mapping, set '" + parent + "." + key + "' manually")
+			}
+		}
+		indent := parentLine[:len(parentLine)-len(strings.TrimLeft(parentLine, " "))] + "  "
+		for _, l := range lines[lineNumber:] {
+			if strings.TrimSpace(l) == "" || strings.HasPrefix(strings.TrimSpace(l), "#") {
+				continue
+			}
+			if childIndent := len(l) - len(strings.TrimLeft(l, " ")); childIndent > len(indent)-2 {
+				indent = l[:childIndent]
+			}
+			break
+		}
+		lines = append(lines[:lineNumber], append([]string{indent + key + ": " + value}, lines[lineNumber:]...)...)
+		return []byte(strings.Join(lines, "\n")), nil
+	}
+	content := strings.TrimRight(string(data), "\n")
+	return []byte(content + "\n" + parent + ":\n  " + key + ": " + value + "\n"), nil
+}
+
+// splitYAMLComment splits the value from its trailing comment, e.g. ': "123" # the page'. The comment keeps its
+// leading space.
+func splitYAMLComment(value string) (string, string) {
+	var quote rune
+	for i, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && i > 0 && (value[i-1] == ' ' || value[i-1] == '\t'):
+			j := i
+			for j > 0 && (value[j-1] == ' ' || value[j-1] == '\t') {
+				j--
+			}
+			return value[:j], value[j:]
+		}
+	}
+	return value, ""
+}
+
 func CreateManifest() {
 
 	manifest := Manifest{
@@ -146,6 +274,8 @@ func CreateManifest() {
 	manifestString := `---
 name: {{.Name}}
 active: true
+# optional, detected from the remote if not set.
+# defaultBranch: main
 languages:
 	- scala
 types:
@@ -163,7 +293,7 @@ documentation:
 	ignoredDirs:
 		- optional/dir/to/be/ignored/from/the/docs
 `
-	manifestTemplate, err := template.New("manifest").Parse(manifestString)
+	manifestTemplate, err := template.New("manifest").Parse(strings.Replace(manifestString, "\t", "  ", -1))
 	if err != nil {
 		panic(err)
 	}
@@ -195,3 +325,113 @@ func IsSameType(m Manifest, manifestType string) bool {
 	}
 	return false
 }
+
+type ManifestFilter struct {
+	Types, Languages, Platforms []string
+	Active, Inactive            bool
+}
+
+// IsEmpty returns true if the filter matches every manifest.
+func (f ManifestFilter) IsEmpty() bool {
+	return !f.Active && !f.Inactive && len(f.Types) == 0 && len(f.Languages) == 0 && len(f.Platforms) == 0
+}
+
+func (f ManifestFilter) Matches(m Manifest) bool {
+	if f.Active && !m.Active || f.Inactive && m.Active {
+		return false
+	}
+	if len(f.Types) > 0 && !matchesAny(m.Types, f.Types) {
+		return false
+	}
+	if len(f.Languages) > 0 && !matchesAny(m.Languages, f.Languages) {
+		return false
+	}
+	if len(f.Platforms) > 0 && !matchesAny(m.Platforms, f.Platforms) {
+		return false
+	}
+	return true
+}
+
+func matchesAny(values []string, expected []string) bool {
+	for _, v := range values {
+		for _, e := range expected {
+			if strings.EqualFold(v, e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LoadManifests loads the manifest of every repository in the current directory. Repositories without a
+// manifest are skipped.
+func LoadManifests() (Manifests, error) {
+	repos, err := ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	var manifests Manifests
+	for _, repo := range repos {
+		if !HasManifest(repo) {
+			log.Printf("%v: no manifest found. Skipping.", repo)
+			continue
+		}
+		m, err := LoadRepositoryManifest(repo)
+		if err != nil {
+			return nil, errors.New(repo + ": " + err.Error())
+		}
+		m.Readme = ""
+		m.ChangeLog = ""
+		manifests = append(manifests, *m)
+	}
+	sort.Sort(manifests)
+	return manifests, nil
+}
+
+func (e Manifests) Filter(f ManifestFilter) (filtered Manifests) {
+	for _, m := range e {
+		if f.Matches(m) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+var manifestSortKeys = map[string]func(m Manifest) string{
+	"name":       func(m Manifest) string { return m.Name },
+	"repository": func(m Manifest) string { return m.Repository },
+	"language":   func(m Manifest) string { return strings.Join(m.Languages, ",") },
+	"platform":   func(m Manifest) string { return strings.Join(m.Platforms, ",") },
+	"type":       func(m Manifest) string { return strings.Join(m.Types, ",") },
+	"active":     func(m Manifest) string { return strconv.FormatBool(m.Active) },
+}
+
+func (e Manifests) SortBy(key string) error {
+	if key == "" || key == "name" {
+		sort.Sort(e)
+		return nil
+	}
+	sortKey, ok := manifestSortKeys[key]
+	if !ok {
+		return errors.New("unknown sort key: " + key)
+	}
+	sort.SliceStable(e, func(i, j int) bool {
+		return sortKey(e[i]) < sortKey(e[j])
+	})
+	return nil
+}
+
+func (e Manifests) Rows() [][]string {
+	rows := [][]string{{"NAME", "REPOSITORY", "TYPES", "LANGUAGES", "PLATFORMS", "ACTIVE"}}
+	for _, m := range e {
+		rows = append(rows, []string{
+			m.Name,
+			m.Repository,
+			strings.Join(m.Types, ","),
+			strings.Join(m.Languages, ","),
+			strings.Join(m.Platforms, ","),
+			strconv.FormatBool(m.Active),
+		})
+	}
+	return rows
+}
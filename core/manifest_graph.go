@@ -0,0 +1,281 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	DependencyIn   = "in"
+	DependencyOut  = "out"
+	DependencyBoth = "both"
+)
+
+var mermaidIDRegex = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+type GraphNode struct {
+	ID       string
+	Label    string
+	Type     string
+	Service  bool
+	External bool
+}
+
+type GraphEdge struct {
+	From, To  string
+	Label     string
+	Both      bool
+	Implicit  bool
+	External  bool
+	Dedicated bool
+}
+
+type DependencyGraph struct {
+	Nodes map[string]*GraphNode
+	Edges []GraphEdge
+}
+
+// ResolveDependencyName returns the node name of the dependency. Explicit unique names are used as is,
+// dependencies on services of the workspace point to the service itself, otherwise the name defaults
+// to <service>-<dependencyName>, e.g. mainapp-mysql.
+func ResolveDependencyName(service string, d Dependency, services map[string]bool) string {
+	if d.UniqueName != "" {
+		return d.UniqueName
+	}
+	if services[d.Name] {
+		return d.Name
+	}
+	return service + "-" + d.Name
+}
+
+func BuildDependencyGraph(manifests Manifests) *DependencyGraph {
+	g := &DependencyGraph{Nodes: map[string]*GraphNode{}}
+	services := map[string]bool{}
+	for _, m := range manifests {
+		services[m.Name] = true
+		g.Nodes[m.Name] = &GraphNode{ID: m.Name, Label: m.Name, Type: strings.Join(m.Types, ","), Service: true}
+	}
+	for _, m := range manifests {
+		for _, d := range m.Dependencies {
+			name := ResolveDependencyName(m.Name, d, services)
+			if _, ok := g.Nodes[name]; !ok {
+				label := d.Name
+				if d.Version != "" {
+					label = label + " " + d.Version
+				}
+				g.Nodes[name] = &GraphNode{ID: name, Label: label, Type: d.Type, External: d.External}
+			}
+			edge := GraphEdge{
+				From:      m.Name,
+				To:        name,
+				Label:     d.Description,
+				Implicit:  d.Implicit,
+				External:  d.External,
+				Dedicated: d.Dedicated,
+			}
+			switch d.Direction {
+			case DependencyIn:
+				edge.From, edge.To = edge.To, edge.From
+			case DependencyBoth:
+				edge.Both = true
+			}
+			g.addEdge(edge)
+		}
+	}
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From == g.Edges[j].From {
+			return g.Edges[i].To < g.Edges[j].To
+		}
+		return g.Edges[i].From < g.Edges[j].From
+	})
+	return g
+}
+
+// addEdge merges the edge with the same one declared by the other service, e.g. 'out' in one manifest and 'in' in the
+// other, or 'both' in the two. The edge stays implicit only if both declarations are.
+func (g *DependencyGraph) addEdge(edge GraphEdge) {
+	for i, e := range g.Edges {
+		same := e.From == edge.From && e.To == edge.To
+		reversed := e.From == edge.To && e.To == edge.From
+		if e.Both != edge.Both || !same && !(e.Both && reversed) {
+			continue
+		}
+		if e.Label == "" {
+			g.Edges[i].Label = edge.Label
+		}
+		g.Edges[i].Implicit = e.Implicit && edge.Implicit
+		g.Edges[i].External = e.External || edge.External
+		g.Edges[i].Dedicated = e.Dedicated || edge.Dedicated
+		return
+	}
+	g.Edges = append(g.Edges, edge)
+}
+
+func (g *DependencyGraph) sortedNodes() (nodes []*GraphNode) {
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
+
+// Cycles lists the cycles between the nodes, e.g. [a b c a]. Dependencies declared in both directions are
+// not considered.
+func (g *DependencyGraph) Cycles() (cycles [][]string) {
+	adjacency := map[string][]string{}
+	for _, e := range g.Edges {
+		if e.Both {
+			continue
+		}
+		adjacency[e.From] = append(adjacency[e.From], e.To)
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(node string)
+	visit = func(node string) {
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, next := range adjacency[node] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == next {
+						cycle := append([]string{}, stack[i:]...)
+						cycles = append(cycles, append(cycle, next))
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = visited
+	}
+	for _, n := range g.sortedNodes() {
+		if state[n.ID] == unvisited {
+			visit(n.ID)
+		}
+	}
+	return cycles
+}
+
+func (g *DependencyGraph) DOT() string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph dependencies {\n")
+	buf.WriteString("  rankdir=LR;\n")
+	buf.WriteString("  node [shape=box];\n")
+	for _, n := range g.sortedNodes() {
+		attrs := []string{"label=" + quoteDOT(n.Label)}
+		if !n.Service {
+			attrs = append(attrs, "shape="+nodeShape(n))
+		}
+		if n.External {
+			attrs = append(attrs, "style=dashed", "color=gray40")
+		}
+		fmt.Fprintf(&buf, "  %v [%v];\n", quoteDOT(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		var attrs []string
+		if e.Label != "" {
+			attrs = append(attrs, "label="+quoteDOT(e.Label))
+		}
+		if e.Both {
+			attrs = append(attrs, "dir=both")
+		}
+		if e.Implicit {
+			attrs = append(attrs, "style=dotted")
+		} else if e.External {
+			attrs = append(attrs, "style=dashed")
+		} else if e.Dedicated {
+			attrs = append(attrs, "style=bold")
+		}
+		if e.External {
+			attrs = append(attrs, "color=gray40")
+		}
+		line := fmt.Sprintf("  %v -> %v", quoteDOT(e.From), quoteDOT(e.To))
+		if len(attrs) > 0 {
+			line += " [" + strings.Join(attrs, ", ") + "]"
+		}
+		buf.WriteString(line + ";\n")
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+func (g *DependencyGraph) Mermaid() string {
+	var buf bytes.Buffer
+	buf.WriteString("graph LR\n")
+	for _, n := range g.sortedNodes() {
+		label := strings.Replace(n.Label, `"`, "'", -1)
+		switch {
+		case n.Service:
+			fmt.Fprintf(&buf, "  %v[\"%v\"]\n", mermaidID(n.ID), label)
+		case nodeShape(n) == "cylinder":
+			fmt.Fprintf(&buf, "  %v[(\"%v\")]\n", mermaidID(n.ID), label)
+		default:
+			fmt.Fprintf(&buf, "  %v([\"%v\"])\n", mermaidID(n.ID), label)
+		}
+	}
+	var externalEdges []string
+	for i, e := range g.Edges {
+		arrow := "-->"
+		if e.Implicit || e.External {
+			arrow = "-.->"
+		} else if e.Dedicated {
+			arrow = "==>"
+		}
+		if e.Both {
+			arrow = "<" + arrow
+		}
+		if e.Label != "" {
+			arrow += "|" + strings.Replace(e.Label, "|", "/", -1) + "|"
+		}
+		fmt.Fprintf(&buf, "  %v %v %v\n", mermaidID(e.From), arrow, mermaidID(e.To))
+		if e.External {
+			externalEdges = append(externalEdges, fmt.Sprint(i))
+		}
+	}
+	var externalNodes []string
+	for _, n := range g.sortedNodes() {
+		if n.External {
+			externalNodes = append(externalNodes, mermaidID(n.ID))
+		}
+	}
+	if len(externalNodes) > 0 {
+		buf.WriteString("  classDef external stroke-dasharray: 5 5,stroke:#666;\n")
+		fmt.Fprintf(&buf, "  class %v external;\n", strings.Join(externalNodes, ","))
+	}
+	if len(externalEdges) > 0 {
+		fmt.Fprintf(&buf, "  linkStyle %v stroke:#666;\n", strings.Join(externalEdges, ","))
+	}
+	return buf.String()
+}
+
+func nodeShape(n *GraphNode) string {
+	switch strings.ToLower(n.Type) {
+	case "database", "db", "storage":
+		return "cylinder"
+	case "queue", "broker":
+		return "cds"
+	}
+	return "ellipse"
+}
+
+func quoteDOT(s string) string {
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+func mermaidID(s string) string {
+	return mermaidIDRegex.ReplaceAllString(s, "_")
+}
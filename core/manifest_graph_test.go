@@ -0,0 +1,57 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildDependencyGraph(t *testing.T) {
+	t.Parallel()
+	manifests := Manifests{
+		{Name: "mainapp", Dependencies: []Dependency{
+			{Name: "mysql", Type: "database", Dedicated: true},
+			{Name: "billing"},
+			{Name: "activemq", UniqueName: "activemq", Direction: "both", Implicit: true},
+		}},
+		{Name: "billing", Dependencies: []Dependency{
+			{Name: "stripe", External: true},
+			{Name: "mainapp", Direction: "in"},
+			{Name: "activemq", UniqueName: "activemq", Direction: "both"},
+			{Name: "ledger", Direction: "both", Description: "balances"},
+		}},
+		{Name: "ledger", Dependencies: []Dependency{
+			{Name: "billing", Direction: "both"},
+		}},
+	}
+	g := BuildDependencyGraph(manifests)
+	assert.Len(t, g.Nodes, 6)
+	assert.Contains(t, g.Nodes, "mainapp-mysql")
+	assert.Contains(t, g.Nodes, "billing-stripe")
+	assert.Contains(t, g.Nodes, "activemq")
+	// mainapp -> billing and billing <-> ledger are declared by both services.
+	assert.Len(t, g.Edges, 6)
+
+	dot := g.DOT()
+	assert.Equal(t, 1, strings.Count(dot, `"mainapp" -> "billing";`))
+	assert.Contains(t, dot, `"billing" -> "ledger" [label="balances", dir=both];`)
+	assert.NotContains(t, dot, `"ledger" -> "billing"`)
+	assert.Contains(t, dot, `"mainapp" -> "mainapp-mysql" [style=bold];`)
+	assert.Contains(t, dot, `"billing" -> "billing-stripe" [style=dashed, color=gray40];`)
+	assert.Contains(t, dot, `"mainapp" -> "activemq" [dir=both, style=dotted];`)
+	mermaid := g.Mermaid()
+	assert.True(t, strings.HasPrefix(mermaid, "graph LR\n"))
+	assert.Contains(t, mermaid, "mainapp ==> mainapp_mysql")
+	assert.Empty(t, g.Cycles())
+}
+
+func TestDependencyGraphCycles(t *testing.T) {
+	t.Parallel()
+	manifests := Manifests{
+		{Name: "a", Dependencies: []Dependency{{Name: "b"}}},
+		{Name: "b", Dependencies: []Dependency{{Name: "c"}}},
+		{Name: "c", Dependencies: []Dependency{{Name: "a"}}},
+	}
+	assert.Equal(t, [][]string{{"a", "b", "c", "a"}}, BuildDependencyGraph(manifests).Cycles())
+}
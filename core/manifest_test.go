@@ -0,0 +1,31 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetManifestValue(t *testing.T) {
+	t.Parallel()
+	set := func(manifest string) string {
+		data, err := setManifestValue([]byte(manifest), "documentation", "pageId", `"42"`)
+		assert.Nil(t, err)
+		return string(data)
+	}
+	assert.Equal(t,
+		"name: nub\ndocumentation:\n    pageId: \"42\"\n    ignoredDirs:\n    - docs\n",
+		set("name: nub\ndocumentation:\n    ignoredDirs:\n    - docs\n"))
+	assert.Equal(t,
+		"name: nub\ndocumentation:\n  pageId: \"42\"\n",
+		set("name: nub\ndocumentation:\n  pageId: old\n"))
+	assert.Equal(t,
+		"name: nub\ndocumentation:\n  pageId: \"42\"\n",
+		set("name: nub\n\n"))
+	assert.Equal(t,
+		"name: nub\ndocumentation: # the docs\n  pageId: \"42\"  # set by nub\n",
+		set("name: nub\ndocumentation: # the docs\n  pageId: \"1 # 2\"  # set by nub\n"))
+
+	_, err := setManifestValue([]byte("name: nub\ndocumentation: {ignoredDirs: [docs]}\n"), "documentation", "pageId", `"42"`)
+	assert.NotNil(t, err, "flow mappings are not supported")
+}
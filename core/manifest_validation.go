@@ -0,0 +1,290 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var DefaultManifestTypes = []string{
+	"service",
+	"library",
+	"application",
+	"tool",
+	"website",
+	"infrastructure",
+	"documentation",
+}
+
+var dependencyDirections = []string{"in", "out", "both"}
+
+type ManifestError struct {
+	File    string
+	Line    int
+	Field   string
+	Message string
+}
+
+func (e ManifestError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location = fmt.Sprintf("%v:%v", location, e.Line)
+	}
+	if e.Field == "" {
+		return fmt.Sprintf("%v: %v", location, e.Message)
+	}
+	return fmt.Sprintf("%v: %v: %v", location, e.Field, e.Message)
+}
+
+type ManifestErrors []ManifestError
+
+func (e ManifestErrors) Error() string {
+	var lines []string
+	for _, i := range e {
+		lines = append(lines, i.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e ManifestErrors) Len() int {
+	return len(e)
+}
+
+func (e ManifestErrors) Less(i, j int) bool {
+	return e[i].Line < e[j].Line
+}
+
+func (e ManifestErrors) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
+// ValidateManifestFile validates the manifest against the schema. Paths referenced in the manifest are
+// resolved relatively to the directory containing the manifest.
+func ValidateManifestFile(filePath string, knownTypes []string) (ManifestErrors, error) {
+	if filePath == "" {
+		filePath = manifestFile
+	}
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	errs := ValidateManifest(data, path.Dir(filePath), knownTypes)
+	for i := range errs {
+		errs[i].File = filePath
+	}
+	return errs, nil
+}
+
+func ValidateManifest(data []byte, baseDir string, knownTypes []string) (errs ManifestErrors) {
+	if len(knownTypes) == 0 {
+		knownTypes = DefaultManifestTypes
+	}
+	m := Manifest{}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return ManifestErrors{{Line: extractYAMLErrorLine(err), Message: err.Error()}}
+	}
+
+	lines := indexManifestLines(data)
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, ManifestError{
+			Line:    lines.lookup(field),
+			Field:   field,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if strings.TrimSpace(m.Name) == "" {
+		add("name", "is required")
+	}
+	for i, t := range m.Types {
+		if !isKnownManifestType(t, knownTypes) {
+			add(fmt.Sprintf("types[%v]", i), "'%v' is not a known type, must be one of: %v", t, strings.Join(knownTypes, ", "))
+		}
+	}
+	for i, d := range m.Dependencies {
+		field := fmt.Sprintf("dependencies[%v]", i)
+		if strings.TrimSpace(d.Name) == "" {
+			add(field+".name", "is required")
+		}
+		if d.Direction != "" && !isValidDirection(d.Direction) {
+			add(field+".direction", "'%v' is not valid, must be one of: %v", d.Direction, strings.Join(dependencyDirections, ", "))
+		}
+	}
+	for i, p := range m.Protocols {
+		if p.Path == "" {
+			continue
+		}
+		if !isDirectory(baseDir, p.Path) {
+			add(fmt.Sprintf("protocols[%v].path", i), "'%v' is not an existing directory", p.Path)
+		}
+	}
+	for i, dir := range m.Documentation.IgnoredDirs {
+		if !isDirectory(baseDir, dir) {
+			add(fmt.Sprintf("documentation.ignoredDirs[%v]", i), "'%v' is not an existing directory", dir)
+		}
+	}
+	if m.Documentation.Template != "" && !isFile(baseDir, m.Documentation.Template) {
+		add("documentation.template", "'%v' is not an existing file", m.Documentation.Template)
+	}
+	sort.Stable(errs)
+	return errs
+}
+
+func isKnownManifestType(manifestType string, knownTypes []string) bool {
+	for _, t := range knownTypes {
+		if t == manifestType {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidDirection(direction string) bool {
+	for _, d := range dependencyDirections {
+		if d == direction {
+			return true
+		}
+	}
+	return false
+}
+
+func isDirectory(baseDir, dir string) bool {
+	if !path.IsAbs(dir) {
+		dir = path.Join(baseDir, dir)
+	}
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+func isFile(baseDir, file string) bool {
+	if !path.IsAbs(file) {
+		file = path.Join(baseDir, file)
+	}
+	info, err := os.Stat(file)
+	return err == nil && !info.IsDir()
+}
+
+func extractYAMLErrorLine(err error) int {
+	match := regexp.MustCompile(`line (\d+)`).FindStringSubmatch(err.Error())
+	if len(match) < 2 {
+		return 0
+	}
+	line, _ := strconv.Atoi(match[1])
+	return line
+}
+
+type manifestLines map[string]int
+
+// lookup returns the line of the field or of its closest parent, 0 if the field is not in the file.
+func (l manifestLines) lookup(field string) int {
+	for field != "" {
+		if line, ok := l[field]; ok {
+			return line
+		}
+		pos := strings.LastIndexAny(field, ".[")
+		if pos < 0 {
+			break
+		}
+		field = field[:pos]
+	}
+	return 0
+}
+
+// indexManifestLines maps the fields of a block style YAML document, e.g. 'dependencies[1].direction', to
+// their line number. yaml.v2 does not expose the positions of the decoded values.
+func indexManifestLines(data []byte) manifestLines {
+	type frame struct {
+		column int
+		path   string
+		isItem bool
+	}
+	var stack []frame
+	lines := manifestLines{}
+	itemCount := map[string]int{}
+	blockScalarColumn := -1
+
+	parent := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		return stack[len(stack)-1].path
+	}
+	pushKey := func(column int, key string, lineNumber int) {
+		for len(stack) > 0 && stack[len(stack)-1].column >= column {
+			stack = stack[:len(stack)-1]
+		}
+		p := key
+		if parent() != "" {
+			p = parent() + "." + key
+		}
+		stack = append(stack, frame{column: column, path: p})
+		lines[p] = lineNumber
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNumber := i + 1
+		content := strings.TrimLeft(line, " ")
+		column := len(line) - len(content)
+		if blockScalarColumn >= 0 {
+			if column > blockScalarColumn || strings.TrimSpace(content) == "" {
+				continue
+			}
+			blockScalarColumn = -1
+		}
+		if content == "" || strings.HasPrefix(content, "#") || strings.HasPrefix(content, "---") {
+			continue
+		}
+
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				if top.column > column || (top.column == column && top.isItem) {
+					stack = stack[:len(stack)-1]
+					continue
+				}
+				break
+			}
+			p := fmt.Sprintf("%v[%v]", parent(), itemCount[parent()])
+			itemCount[parent()]++
+			stack = append(stack, frame{column: column, path: p, isItem: true})
+			lines[p] = lineNumber
+
+			item := strings.TrimPrefix(content, "-")
+			trimmedItem := strings.TrimLeft(item, " ")
+			column += 1 + len(item) - len(trimmedItem)
+			content = trimmedItem
+		}
+
+		key, value, isKey := splitYAMLKey(content)
+		if !isKey {
+			continue
+		}
+		pushKey(column, key, lineNumber)
+		if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			blockScalarColumn = column
+		}
+	}
+	return lines
+}
+
+func splitYAMLKey(content string) (key, value string, ok bool) {
+	pos := strings.Index(content, ": ")
+	if pos < 0 {
+		if !strings.HasSuffix(content, ":") {
+			return "", "", false
+		}
+		pos = len(content) - 1
+	}
+	key = strings.Trim(content[:pos], `"' `)
+	if key == "" || strings.ContainsAny(key, "{}[],") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(content[pos+1:]), true
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const invalidManifest = `---
+active: true
+types:
+  - service
+  - spaceship
+dependencies:
+  - name: activemq
+    direction: sideways
+  - name: postgres
+    version: 9.6
+protocols:
+  - type: raml
+    path: does/not/exist
+documentation:
+  ignoredDirs:
+  - .
+  - missing
+`
+
+func TestValidateManifest(t *testing.T) {
+	t.Parallel()
+	errs := ValidateManifest([]byte(invalidManifest), ".", nil)
+	assert.Len(t, errs, 5)
+	assert.Equal(t, ManifestError{Line: 0, Field: "name", Message: "is required"}, errs[0])
+	assert.Equal(t, 5, errs[1].Line)
+	assert.Equal(t, "types[1]", errs[1].Field)
+	assert.Equal(t, 8, errs[2].Line)
+	assert.Equal(t, "dependencies[0].direction", errs[2].Field)
+	assert.Equal(t, 13, errs[3].Line)
+	assert.Equal(t, "protocols[0].path", errs[3].Field)
+	assert.Equal(t, 17, errs[4].Line)
+	assert.Equal(t, "documentation.ignoredDirs[1]", errs[4].Field)
+}
+
+func TestValidateManifestSyntaxError(t *testing.T) {
+	t.Parallel()
+	errs := ValidateManifest([]byte("name: nub\ntypes: [service\n"), ".", nil)
+	assert.Len(t, errs, 1)
+	assert.True(t, errs[0].Line > 0)
+}
+
+func TestValidateManifestKnownTypes(t *testing.T) {
+	t.Parallel()
+	errs := ValidateManifest([]byte("name: nub\ntypes:\n- cli\n"), ".", []string{"cli"})
+	assert.Empty(t, errs)
+}
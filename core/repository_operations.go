@@ -0,0 +1,222 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+)
+
+const DefaultConcurrency = 8
+
+type RepositoryOperationOptions struct {
+	// maximum number of repositories processed at the same time.
+	Concurrency int
+	// the commands of the operation are killed after the timeout, 0 means no timeout.
+	Timeout time.Duration
+	// path of the JSON report of the results, '-' for stdout.
+	Report string
+	// repositories of the current directory ForEachRepo operates on.
+	Selection RepositorySelection
+}
+
+type ConcurrentResult struct {
+	Repository string  `json:"repository"`
+	Success    bool    `json:"success"`
+	Error      string  `json:"error,omitempty"`
+	Output     string  `json:"output,omitempty"`
+	Duration   float64 `json:"durationSeconds"`
+	Err        error   `json:"-"`
+}
+
+// ConcurrentResults are in the same order as the repositories passed.
+type ConcurrentResults []ConcurrentResult
+
+func (r ConcurrentResults) Failed() (failed ConcurrentResults) {
+	for _, result := range r {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// WriteReport writes the results as JSON to the file, or stdout if the path is '-'.
+func (r ConcurrentResults) WriteReport(reportPath string) error {
+	report := struct {
+		Succeeded int               `json:"succeeded"`
+		Failed    int               `json:"failed"`
+		Results   ConcurrentResults `json:"results"`
+	}{Succeeded: len(r) - len(r.Failed()), Failed: len(r.Failed()), Results: r}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if reportPath == "-" {
+		fmt.Println(string(data))
+		return nil
+	}
+	log.Printf("Writing the report to %v", reportPath)
+	return ioutil.WriteFile(reportPath, data, 0644)
+}
+
+func ForEachRepo(opts RepositoryOperationOptions, fn RepoOperation) error {
+	repos, err := ListSelectedRepositories(opts.Selection)
+	if err != nil {
+		return err
+	}
+	if len(repos) == 0 {
+		return errors.New("no repository matches the selection")
+	}
+	return ConcurrentRepositoryOperations(repos, opts, fn)
+}
+
+// ConcurrentRepositoryOperations runs the operation on every repository, prints the outputs in order and reports
+// the failures.
+func ConcurrentRepositoryOperations(repos []string, opts RepositoryOperationOptions, fn RepoOperation) error {
+	results := RunConcurrentRepositoryOperations(repos, opts, fn)
+	for _, result := range results {
+		if result.Output != "" {
+			fmt.Println(result.Output)
+		}
+	}
+	for _, result := range results.Failed() {
+		log.Printf("%v failed to be updated: %v", result.Repository, result.Err)
+	}
+	if opts.Report != "" {
+		if err := results.WriteReport(opts.Report); err != nil {
+			return err
+		}
+	}
+	if failed := len(results.Failed()); failed > 0 {
+		log.Printf("%v repos failed to be updated.", failed)
+		return errors.New("some repos failed to update")
+	}
+	log.Print("All Done.")
+	return nil
+}
+
+// RunConcurrentRepositoryOperations runs the operation on the repositories with a pool of workers. The results are
+// returned in the order of the repositories.
+func RunConcurrentRepositoryOperations(repos []string, opts RepositoryOperationOptions, fn RepoOperation) ConcurrentResults {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	results := make(ConcurrentResults, len(repos))
+	progress := newProgress(len(repos))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(repos); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				repo := repos[i]
+				start := time.Now()
+				output, err := runWithTimeout(repo, opts.Timeout, fn)
+				result := ConcurrentResult{
+					Repository: repo,
+					Success:    err == nil,
+					Output:     output,
+					Duration:   time.Since(start).Seconds(),
+					Err:        err,
+				}
+				if err != nil {
+					result.Error = err.Error()
+				}
+				results[i] = result
+				progress.done(repo, err)
+			}
+		}()
+	}
+	for i := range repos {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	progress.finish()
+	return results
+}
+
+// runWithTimeout cancels the context of the operation once the timeout is reached, which kills the commands it
+// started. The operation is waited for so that the concurrency is not exceeded.
+func runWithTimeout(repo string, timeout time.Duration, fn RepoOperation) (string, error) {
+	if timeout <= 0 {
+		return fn(context.Background(), repo)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	output, err := fn(ctx, repo)
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, errors.Errorf("timed out after %v", timeout)
+	}
+	return output, err
+}
+
+// progress prints a progress line on terminals, or a log line per repository otherwise. On terminals, the log lines
+// are written through the progress so that the progress line is cleared first and redrawn after.
+type progress struct {
+	mutex                  sync.Mutex
+	total, count, failures int
+	terminal               bool
+	line                   string
+	logOutput              io.Writer
+}
+
+func newProgress(total int) *progress {
+	p := &progress{total: total, terminal: isatty.IsTerminal(os.Stderr.Fd())}
+	if p.terminal {
+		p.logOutput = log.Writer()
+		log.SetOutput(p)
+	}
+	return p
+}
+
+func (p *progress) Write(data []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.line != "" {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	n, err := p.logOutput.Write(data)
+	fmt.Fprint(os.Stderr, p.line)
+	return n, err
+}
+
+func (p *progress) done(repo string, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.count++
+	if err != nil {
+		p.failures++
+	}
+	if p.terminal {
+		p.line = fmt.Sprintf("[%v/%v] %v failed, last: %v", p.count, p.total, p.failures, repo)
+		fmt.Fprint(os.Stderr, "\r\033[K"+p.line)
+		return
+	}
+	status := "done"
+	if err != nil {
+		status = "failed"
+	}
+	log.Printf("[%v/%v] %v: %v.", p.count, p.total, repo, status)
+}
+
+func (p *progress) finish() {
+	if !p.terminal {
+		return
+	}
+	log.SetOutput(p.logOutput)
+	if p.line != "" {
+		fmt.Fprintln(os.Stderr)
+	}
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/j-martin/nub/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunConcurrentRepositoryOperations(t *testing.T) {
+	t.Parallel()
+	repos := []string{"a", "b", "c", "d", "e", "f"}
+	var running, maxRunning int32
+	results := RunConcurrentRepositoryOperations(repos, RepositoryOperationOptions{Concurrency: 2}, func(ctx context.Context, repo string) (string, error) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			previous := atomic.LoadInt32(&maxRunning)
+			if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if repo == "c" {
+			return "", errors.New("boom")
+		}
+		return "output " + repo, nil
+	})
+	assert.True(t, maxRunning <= 2)
+	assert.Len(t, results, len(repos))
+	for i, r := range results {
+		assert.Equal(t, repos[i], r.Repository)
+	}
+	assert.Equal(t, "output a", results[0].Output)
+	assert.Len(t, results.Failed(), 1)
+	assert.Equal(t, "boom", results[2].Error)
+	assert.False(t, results[2].Success)
+}
+
+func TestRunConcurrentRepositoryOperationsTimeout(t *testing.T) {
+	t.Parallel()
+	opts := RepositoryOperationOptions{Timeout: 10 * time.Millisecond}
+	start := time.Now()
+	results := RunConcurrentRepositoryOperations([]string{"slow"}, opts, func(ctx context.Context, repo string) (string, error) {
+		// the sleep is a child of the shell, killing the shell alone would leave it running.
+		return utils.RunCmdWithFullOutputContext(ctx, "sh", "-c", "sleep 5; echo done")
+	})
+	assert.Contains(t, results[0].Error, "timed out")
+	assert.True(t, time.Since(start) < 4*time.Second, "the commands are killed on timeout")
+}
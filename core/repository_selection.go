@@ -0,0 +1,127 @@
+package core
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type RepositorySelection struct {
+	// glob patterns matched against the directory names, e.g. 'api-*'.
+	Include, Exclude []string
+	// file listing the repositories, one per line. Empty lines and lines starting with # are ignored.
+	ReposFile string
+	// the repositories must have a manifest matching the filter, unless the filter is empty.
+	Manifest ManifestFilter
+}
+
+// ListSelectedRepositories lists the git repositories of the current directory matching the selection.
+func ListSelectedRepositories(s RepositorySelection) ([]string, error) {
+	repos, err := ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	if s.ReposFile != "" {
+		listed, err := readReposFile(s.ReposFile)
+		if err != nil {
+			return nil, err
+		}
+		repos, err = intersectRepositories(repos, listed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var selected []string
+	for _, repo := range repos {
+		matches, err := s.matchesName(repo)
+		if err != nil {
+			return nil, err
+		}
+		if !matches || !s.matchesManifest(repo) {
+			continue
+		}
+		selected = append(selected, repo)
+	}
+	return selected, nil
+}
+
+func (s RepositorySelection) matchesName(repo string) (bool, error) {
+	included := len(s.Include) == 0
+	for _, pattern := range s.Include {
+		matches, err := path.Match(pattern, repo)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid pattern '%v'", pattern)
+		}
+		included = included || matches
+	}
+	for _, pattern := range s.Exclude {
+		matches, err := path.Match(pattern, repo)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid pattern '%v'", pattern)
+		}
+		if matches {
+			return false, nil
+		}
+	}
+	return included, nil
+}
+
+func (s RepositorySelection) matchesManifest(repo string) bool {
+	if s.Manifest.IsEmpty() {
+		return true
+	}
+	if !HasManifest(repo) {
+		return false
+	}
+	m, err := LoadRepositoryManifest(repo)
+	if err != nil {
+		log.Printf("%v: failed to load the manifest: %v", repo, err)
+		return false
+	}
+	return s.Manifest.Matches(*m)
+}
+
+// readReposFile returns the repositories listed in the file, in order. The repositories listed more than once are
+// only kept once, otherwise they would be processed concurrently on the same tree.
+func readReposFile(filePath string) (repos []string, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	listed := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		repo := strings.TrimSuffix(line, "/")
+		if listed[repo] {
+			continue
+		}
+		listed[repo] = true
+		repos = append(repos, repo)
+	}
+	return repos, scanner.Err()
+}
+
+// intersectRepositories keeps the listed repositories, in the order of the list. They must exist.
+func intersectRepositories(existing, listed []string) ([]string, error) {
+	known := map[string]bool{}
+	for _, r := range existing {
+		known[r] = true
+	}
+	var repos []string
+	for _, r := range listed {
+		if !known[r] {
+			return nil, errors.Errorf("'%v' listed in the repos file is not a repository of the current directory", r)
+		}
+		repos = append(repos, r)
+	}
+	return repos, nil
+}
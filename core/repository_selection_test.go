@@ -0,0 +1,52 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositorySelectionMatchesName(t *testing.T) {
+	t.Parallel()
+	s := RepositorySelection{Include: []string{"api-*", "web"}, Exclude: []string{"*-legacy"}}
+	for repo, expected := range map[string]bool{
+		"api-users":  true,
+		"web":        true,
+		"api-legacy": false,
+		"worker":     false,
+	} {
+		matches, err := s.matchesName(repo)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, matches, repo)
+	}
+
+	matches, err := RepositorySelection{}.matchesName("anything")
+	assert.Nil(t, err)
+	assert.True(t, matches)
+
+	_, err = RepositorySelection{Include: []string{"["}}.matchesName("anything")
+	assert.NotNil(t, err)
+}
+
+func TestReadReposFile(t *testing.T) {
+	t.Parallel()
+	file, err := ioutil.TempFile("", "nub-repos")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("# services\nusers\n\nbilling/\nusers\nbilling\n")
+	assert.Nil(t, err)
+	file.Close()
+
+	listed, err := readReposFile(file.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"users", "billing"}, listed)
+
+	repos, err := intersectRepositories([]string{"billing", "users", "web"}, listed)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"users", "billing"}, repos)
+
+	_, err = intersectRepositories([]string{"web"}, listed)
+	assert.NotNil(t, err)
+}
@@ -6,17 +6,19 @@ import (
 	"log"
 	"os"
 
-	"bufio"
 	"bytes"
 	"github.com/bndr/gopencils"
 	"github.com/j-martin/nub/core"
 	"github.com/j-martin/nub/utils"
 	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/russross/blackfriday"
 	"gopkg.in/yaml.v2"
+	"net/url"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"text/template"
@@ -62,7 +64,8 @@ type PageInfo struct {
 	} `json:"version"`
 
 	Ancestors []struct {
-		Id string `json:"id"`
+		Id    string `json:"id"`
+		Title string `json:"title"`
 	} `json:"ancestors"`
 }
 
@@ -73,11 +76,18 @@ type PageBody struct {
 type PageBodyValue struct {
 	Value string `json:"value"`
 }
+
+const searchPageSize = 100
+
 type SearchResult struct {
-	ID         string `json:"id"`
-	Type       string `json:"type"`
-	Status     string `json:"status"`
-	Title      string `json:"title"`
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Title  string `json:"title"`
+	Space  struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
+	} `json:"space"`
 	ChildTypes struct {
 	} `json:"childTypes"`
 	Restrictions struct {
@@ -125,8 +135,12 @@ func (c *Confluence) marshallManifest(m core.Manifest) (string, error) {
 	return string(i), err
 }
 
-func (c *Confluence) findMarkdownFiles(ignoreDirs []string, ignoreCommonFiles bool) (fileList []string, err error) {
-	err = filepath.Walk(".", func(filePath string, f os.FileInfo, err error) error {
+func (c *Confluence) findMarkdownFiles(repoDir string, ignoreDirs []string, ignoreCommonFiles bool) (fileList []string, err error) {
+	err = filepath.Walk(repoDir, func(filePath string, f os.FileInfo, err error) error {
+		filePath, err = filepath.Rel(repoDir, filePath)
+		if err != nil {
+			return err
+		}
 		ignoredRootDir := append(ignoreDirs,
 			".github",
 			"bower_components/",
@@ -171,47 +185,42 @@ func (c *Confluence) findMarkdownFiles(ignoreDirs []string, ignoreCommonFiles bo
 	return fileList, err
 }
 
-func (c *Confluence) joinMarkdownFiles(m *core.Manifest) (content []byte, err error) {
-	files, err := c.findMarkdownFiles(m.Documentation.IgnoredDirs, true)
-	if err != nil {
-		return nil, err
-	}
+func (c *Confluence) joinMarkdownFiles(m *core.Manifest, repoDir string, files []string, included map[string]bool) (content []byte, images []string, err error) {
 	for _, filePath := range files {
-		fileContent, err := ioutil.ReadFile(filePath)
+		fileContent, err := ioutil.ReadFile(path.Join(repoDir, filePath))
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		fileContent = addHeadingAnchors(fileContent, filePath)
+		fileContent, fileImages := rewriteImagePaths(fileContent, path.Dir(filePath))
+		fileContent = rewriteLinks(fileContent, path.Dir(filePath), included, c.gitHubURLBuilder(m))
+		images = append(images, fileImages...)
 		url := c.generateGitHubLink(filePath, m)
-		content = append(content, []byte("\n\n\n---\n#### From "+url+"\n")...)
+		content = append(content, []byte(anchorMarkdown(filePath)+"\n---\n#### From "+url+"\n")...)
 		content = append(content, fileContent...)
 	}
-	return content, err
+	return content, images, err
 }
 
-func (c *Confluence) generateGitHubLink(filePath string, m *core.Manifest) string {
-	return "[" + filePath + "](https://github.com/" + path.Join(c.cfg.GitHub.Organization, m.Repository, "blob/master", filePath) + ")"
+func (c *Confluence) gitHubURLBuilder(m *core.Manifest) func(string) string {
+	return func(filePath string) string {
+		return "https://github.com/" + path.Join(c.cfg.GitHub.Organization, m.Repository, "blob", m.DefaultBranch, filePath)
+	}
 }
 
-func (c *Confluence) createPage(m *core.Manifest) ([]byte, error) {
-	marshaledManifest, err := c.marshallManifest(*m)
-	if err != nil {
-		return nil, err
-	}
+func (c *Confluence) generateGitHubLink(filePath string, m *core.Manifest) string {
+	return "[" + filePath + "](" + c.gitHubURLBuilder(m)(filePath) + ")"
+}
 
-	t, err := template.New("readme").Parse(`
+const defaultPageTemplate = `
 <ac:structured-macro ac:name="info" ac:schema-version="1" ac:macro-id="9289e233-4abf-4957-8884-bef7be9ead8e"><ac:rich-text-body>
 <p>This page is automatically generated. Any changes will be lost.
-	Edit the actual <a href="https://github.com/{{ .Config.GitHub.Organization }}/{{ .Manifest.Repository }}">README</a> instead.</p>
+	Edit the actual <a href="{{ .RepositoryURL }}">README</a> instead.</p>
 </ac:rich-text-body></ac:structured-macro>
 
 <p>
-	<a href="https://github.com/{{ .Config.GitHub.Organization }}/{{ .Manifest.Repository }}">Repository</a> |
-	<strong>Diffs</strong>
-		<a href="https://github.com/{{ .Config.GitHub.Organization }}/{{ .Manifest.Repository }}/compare/production...master" title="Pending changes from master to Production">Production / Master</a> /
-		<a href="https://github.com/{{ .Config.GitHub.Organization }}/{{ .Manifest.Repository }}/compare/production...staging" title="Pending changes from Staging to Production">Staging / Production</a> /
-		<a href="https://github.com/{{ .Config.GitHub.Organization }}/{{ .Manifest.Repository }}/compare/production-rollback...production" title="Changes in the previous deployment.">Previous / Current Production</a> |
-	<a href="{{ .Config.Jenkins.Server }}/job/{{ .Config.GitHub.Organization }}/job/{{ .Manifest.Repository }}">Jenkins</a> |
-	<a href="{{ .Config.Splunk.Server }}/en-US/app/search/search/?dispatch.sample_ratio=1&amp;earliest=rt-1h&amp;latest=rtnow&amp;q=search%20sourcetype%3D{{ .Manifest.Deploy.Environment }}-{{ .Manifest.Name }}*&amp;display.page.search.mode=smart">Splunk</a>
+	<a href="{{ .RepositoryURL }}">Repository</a>{{ range $name, $url := .Links }} |
+	<a href="{{ $url }}">{{ $name }}</a>{{ end }}
 </p>
 
 <p>
@@ -222,33 +231,106 @@ func (c *Confluence) createPage(m *core.Manifest) ([]byte, error) {
 		</ac:plain-text-body></ac:structured-macro>
 	</ac:rich-text-body></ac:structured-macro>
 </p>
-`)
+`
+
+// PageTemplateData holds the variables available to the page header template and to the links.
+type PageTemplateData struct {
+	Manifest          core.Manifest
+	Organization      string
+	Repository        string
+	RepositoryURL     string
+	Links             map[string]string
+	MarshaledManifest string
+}
+
+// loadPageTemplate returns the template defined in the manifest, then the one in the config, then the default.
+func (c *Confluence) loadPageTemplate(m *core.Manifest, repoDir string) (string, error) {
+	if m.Documentation.Template != "" {
+		content, err := ioutil.ReadFile(path.Join(repoDir, m.Documentation.Template))
+		if err != nil {
+			return "", errors.Wrap(err, "failed to load the page template defined in the manifest")
+		}
+		return string(content), nil
+	}
+	if c.cfg.Confluence.Template != "" {
+		return c.cfg.Confluence.Template, nil
+	}
+	return defaultPageTemplate, nil
+}
+
+func executeTemplate(name, content string, data interface{}) (string, error) {
+	t, err := template.New(name).Parse(content)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, data)
+	return buf.String(), err
+}
 
+func (c *Confluence) renderPageHeader(m *core.Manifest, repoDir string) (string, error) {
+	marshaledManifest, err := c.marshallManifest(*m)
 	if err != nil {
-		return nil, err
-	}
-	var header bytes.Buffer
-	writer := bufio.NewWriter(&header)
-	err = t.Execute(writer, struct {
-		Config            core.Configuration
-		Manifest          core.Manifest
-		MarshaledManifest string
-	}{
+		return "", err
+	}
+	data := PageTemplateData{
 		Manifest:          *m,
-		Config:            *c.cfg,
+		Organization:      c.cfg.GitHub.Organization,
+		Repository:        m.Repository,
+		RepositoryURL:     "https://github.com/" + path.Join(c.cfg.GitHub.Organization, m.Repository),
+		Links:             map[string]string{},
 		MarshaledManifest: marshaledManifest,
-	})
-	writer.Flush()
+	}
+	for name, link := range c.cfg.Confluence.Links {
+		data.Links[name], err = executeTemplate(name, link, data)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to render the '%v' link", name)
+		}
+	}
+	pageTemplate, err := c.loadPageTemplate(m, repoDir)
+	if err != nil {
+		return "", err
+	}
+	header, err := executeTemplate("header", pageTemplate, data)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to render the page template")
+	}
+	return header, nil
+}
+
+// createPage renders the page and returns the local images, relative to the repository, referenced by the page.
+func (c *Confluence) createPage(m *core.Manifest, repoDir string) ([]byte, []string, error) {
+	header, err := c.renderPageHeader(m, repoDir)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	files, err := c.findMarkdownFiles(repoDir, m.Documentation.IgnoredDirs, true)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
+	}
+	included := map[string]bool{readmeFile: true}
+	for _, f := range files {
+		included[f] = true
 	}
 
-	otherMarkdown, err := c.joinMarkdownFiles(m)
+	readme, images := rewriteImagePaths(addHeadingAnchors([]byte(m.Readme), readmeFile), ".")
+	readme = rewriteLinks(readme, ".", included, c.gitHubURLBuilder(m))
+	otherMarkdown, otherImages, err := c.joinMarkdownFiles(m, repoDir, files, included)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	markdown := append([]byte(anchorMarkdown(readmeFile)), readme...)
+	markdown = append(markdown, otherMarkdown...)
+
+	var existingImages []string
+	for _, i := range append(images, otherImages...) {
+		if exists, _ := utils.PathExists(repoDir, i); !exists {
+			log.Printf("Image '%v' not found. It will not be uploaded.", i)
+			continue
+		}
+		existingImages = append(existingImages, i)
 	}
-	markdown := append([]byte(m.Readme), otherMarkdown...)
 
 	htmlFlags := blackfriday.HTML_USE_XHTML
 	renderer := blackfriday.HtmlRenderer(htmlFlags, "", "")
@@ -263,23 +345,93 @@ func (c *Confluence) createPage(m *core.Manifest) ([]byte, error) {
 
 	opts := blackfriday.Options{Extensions: extensions}
 	renderedMarkdown := blackfriday.MarkdownOptions(markdown, renderer, opts)
-	return append(header.Bytes(), renderedMarkdown...), nil
+	renderedMarkdown = replaceImagesWithAttachments(renderedMarkdown, existingImages)
+	renderedMarkdown = replaceAnchors(renderedMarkdown)
+	return append([]byte(header), renderedMarkdown...), existingImages, nil
 }
 
-func (c *Confluence) UpdateDocumentation(m *core.Manifest) error {
-	if m.Documentation.PageId == "" {
-		log.Print("documenation.pageId: No confluence page defined in manifest. Moving on.")
+type PublishOptions struct {
+	DryRun bool
+	// Create the page if the manifest does not define one.
+	Create bool
+	// Reuse, and overwrite, the page with the same title when creating the page.
+	Force        bool
+	Space        string
+	ParentPageId string
+}
+
+func (c *Confluence) PublishWorkspaceDocumentation(opts PublishOptions) error {
+	repos, err := core.ListRepositories()
+	if err != nil {
+		return err
+	}
+	failures := 0
+	for _, repo := range repos {
+		if !core.HasManifest(repo) {
+			log.Printf("%v: no manifest found. Skipping.", repo)
+			continue
+		}
+		log.Printf("%v: publishing documentation.", repo)
+		if err := c.PublishDocumentation(repo, opts); err != nil {
+			log.Printf("%v: failed to publish the documentation: %v", repo, err)
+			failures++
+		}
+	}
+	if failures > 0 {
+		return errors.Errorf("%v repositories failed to be published", failures)
+	}
+	return nil
+}
+
+func (c *Confluence) PublishDocumentation(repoDir string, opts PublishOptions) error {
+	if repoDir == "" {
+		repoDir = "."
+	}
+	m, err := core.LoadRepositoryManifest(repoDir)
+	if err != nil {
+		return err
+	}
+	return c.UpdateDocumentation(m, repoDir, opts)
+}
+
+func (c *Confluence) UpdateDocumentation(m *core.Manifest, repoDir string, opts PublishOptions) error {
+	if m.Documentation.PageId == "" && !opts.DryRun && !opts.Create {
+		log.Print("documenation.pageId: No confluence page defined in manifest. Use '--create' to create it. Moving on.")
 		return nil
 	}
 
-	htmlData, err := c.createPage(m)
+	htmlData, images, err := c.createPage(m, repoDir)
 	if err != nil {
 		return errors.Errorf("Failed to generate page. %v", err)
 	}
 	newContent := string(htmlData[:])
+	title := strings.Title(m.Name) + " - Readme"
+
+	if opts.DryRun {
+		fmt.Println(newContent)
+	}
+
+	if m.Documentation.PageId == "" {
+		if !opts.Create {
+			log.Print("documenation.pageId: No confluence page defined in manifest. Nothing to compare with.")
+			return nil
+		}
+		pageID, err := c.createDocumentationPage(m, repoDir, title, newContent, opts)
+		if err != nil || pageID == "" {
+			return err
+		}
+		return c.uploadAttachments(pageID, repoDir, images, opts.DryRun)
+	}
 
 	pageInfo, err := c.getPageInfo(m.Documentation.PageId)
-	pageInfo.Title = strings.Title(m.Name) + " - Readme"
+	if err != nil {
+		return err
+	}
+	pageInfo.Title = title
+
+	// the attachments are synced even if the page is unchanged since an image can be edited in place, the images
+	// whose hash did not change are skipped.
+	err = c.uploadAttachments(m.Documentation.PageId, repoDir, images, opts.DryRun)
 	if err != nil {
 		return err
 	}
@@ -290,6 +442,16 @@ func (c *Confluence) UpdateDocumentation(m *core.Manifest) error {
 		return nil
 	}
 
+	if opts.DryRun {
+		diff, err := diffBodies(m.Documentation.PageId, currentBody, newContent)
+		if err != nil {
+			return err
+		}
+		fmt.Println(diff)
+		log.Print("Dry run, the page was not updated.")
+		return nil
+	}
+
 	err = c.updatePage(m.Documentation.PageId, pageInfo, string(htmlData))
 	if err != nil {
 		return err
@@ -299,6 +461,117 @@ func (c *Confluence) UpdateDocumentation(m *core.Manifest) error {
 	return nil
 }
 
+// createDocumentationPage creates the page, or reuses the one with the same title in the space, and writes its id
+// back in the manifest.
+func (c *Confluence) createDocumentationPage(m *core.Manifest, repoDir, title, content string, opts PublishOptions) (string, error) {
+	space := opts.Space
+	if space == "" {
+		space = c.cfg.Confluence.Space
+	}
+	parentID := opts.ParentPageId
+	if parentID == "" {
+		parentID = m.Documentation.ParentPageId
+	}
+	if parentID == "" {
+		parentID = c.cfg.Confluence.ParentPageId
+	}
+	if space == "" {
+		return "", errors.New("the space must be defined to create the page, set 'confluence.space' in the config or use '--space'")
+	}
+
+	pageID, err := c.findPageByTitle(space, title)
+	if err != nil {
+		return "", err
+	}
+	if pageID != "" {
+		if !opts.Force && !opts.DryRun {
+			return "", errors.Errorf("a page titled '%v' already exists in %v (%v), use --force to overwrite it", title, space, pageID)
+		}
+		log.Printf("Existing page '%v' found in %v: %v", title, space, pageID)
+		if !opts.DryRun {
+			pageInfo, err := c.getPageInfo(pageID)
+			if err != nil {
+				return "", err
+			}
+			if err = c.updatePage(pageID, pageInfo, content); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		err = utils.ConditionalOp(fmt.Sprintf("Creating page '%v' in %v under '%v'.", title, space, parentID), opts.DryRun, func() error {
+			pageID, err = c.createContent(space, parentID, title, content)
+			return err
+		})
+		if err != nil || opts.DryRun {
+			return "", err
+		}
+		log.Printf("Page created: %v", pageID)
+	}
+
+	err = utils.ConditionalOp(fmt.Sprintf("Setting documentation.pageId to %v in the manifest.", pageID), opts.DryRun, func() error {
+		return core.SetManifestPageId(repoDir, pageID)
+	})
+	return pageID, err
+}
+
+func (c *Confluence) findPageByTitle(space, title string) (string, error) {
+	cql := fmt.Sprintf("space = \"%v\" AND type = page AND title = \"%v\"", space, strings.Replace(title, `"`, `\"`, -1))
+	results, err := c.Search(cql, 0)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range results {
+		if r.Title == title {
+			return r.ID, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *Confluence) createContent(space, parentID, title, content string) (string, error) {
+	payload := map[string]interface{}{
+		"type":  "page",
+		"title": title,
+		"space": map[string]interface{}{"key": space},
+		"body": map[string]interface{}{
+			"storage": map[string]interface{}{
+				"value":          content,
+				"representation": "storage",
+			},
+		},
+	}
+	if parentID != "" {
+		payload["ancestors"] = []map[string]interface{}{{"id": parentID}}
+	}
+
+	response := &struct {
+		ID string `json:"id"`
+	}{}
+	request, err := c.client.Res("content", response).Post(payload)
+	if err != nil {
+		return "", err
+	}
+	if request.Raw.StatusCode != 200 {
+		output, _ := ioutil.ReadAll(request.Raw.Body)
+		defer request.Raw.Body.Close()
+		return "", fmt.Errorf(
+			"confluence REST API returns unexpected HTTP status: %s, output: %s",
+			request.Raw.Status, output,
+		)
+	}
+	return response.ID, nil
+}
+
+func diffBodies(pageID, current, updated string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(current),
+		B:        difflib.SplitLines(updated),
+		FromFile: pageID + " (current)",
+		ToFile:   pageID + " (updated)",
+		Context:  3,
+	})
+}
+
 func sanitizeBody(body string) string {
 	r := strings.NewReplacer(" ", "", "\n", "", "\t", "")
 	return r.Replace(body)
@@ -307,18 +580,6 @@ func sanitizeBody(body string) string {
 func (c *Confluence) updatePage(pageID string, pageInfo PageInfo, newContent string) error {
 	nextPageVersion := pageInfo.Version.Number + 1
 
-	if len(pageInfo.Ancestors) == 0 {
-		return fmt.Errorf(
-			"page '%s' info does not contain any information about parents",
-			pageID,
-		)
-	}
-
-	// picking only the last one, which is required by confluence
-	oldAncestors := []map[string]interface{}{
-		{"id": pageInfo.Ancestors[len(pageInfo.Ancestors)-1].Id},
-	}
-
 	payload := map[string]interface{}{
 		"id":    pageID,
 		"type":  "page",
@@ -327,7 +588,6 @@ func (c *Confluence) updatePage(pageID string, pageInfo PageInfo, newContent str
 			"number":    nextPageVersion,
 			"minorEdit": false,
 		},
-		"ancestors": oldAncestors,
 		"body": map[string]interface{}{
 			"storage": map[string]interface{}{
 				"value":          newContent,
@@ -336,6 +596,14 @@ func (c *Confluence) updatePage(pageID string, pageInfo PageInfo, newContent str
 		},
 	}
 
+	// top level pages, e.g. the space home page, do not have any ancestors.
+	if len(pageInfo.Ancestors) > 0 {
+		// picking only the last one, which is required by confluence
+		payload["ancestors"] = []map[string]interface{}{
+			{"id": pageInfo.Ancestors[len(pageInfo.Ancestors)-1].Id},
+		}
+	}
+
 	request, err := c.client.Res(
 		"content/"+pageID, &map[string]interface{}{},
 	).Put(payload)
@@ -390,36 +658,94 @@ func (c *Confluence) getPageInfo(pageID string) (PageInfo, error) {
 	return *response, nil
 }
 
-func (c *Confluence) SearchAndReplace(cql, old, new string, noop bool) error {
-	results := c.Search(cql)
+type ReplaceOptions struct {
+	Noop bool
+	// Limit is the maximum number of pages updated, the pages without match are not counted. 0 updates all of them.
+	Limit int
+	// Regex treats the old string as a regular expression, the new string can reference the groups, e.g. $1.
+	Regex bool
+}
+
+func (c *Confluence) SearchAndReplace(cql, old, new string, opts ReplaceOptions) error {
+	replace := func(body string) string {
+		return strings.Replace(body, old, new, -1)
+	}
+	if opts.Regex {
+		re, err := regexp.Compile(old)
+		if err != nil {
+			return err
+		}
+		replace = func(body string) string {
+			return re.ReplaceAllString(body, new)
+		}
+	}
+
+	backupDir := NewBackupDir()
+	results, err := c.Search(cql, 0)
+	if err != nil {
+		return err
+	}
+	updated := 0
 	for _, i := range results {
+		if opts.Limit > 0 && updated >= opts.Limit {
+			log.Printf("Limit of %v page(s) reached. Stopping.", opts.Limit)
+			break
+		}
 		page, err := c.getPageInfo(i.ID)
 		if err != nil {
 			return err
 		}
 		initialBody := page.Body.Storage.Value
-		updatedBody := strings.Replace(initialBody, old, new, -1)
+		updatedBody := replace(initialBody)
 		if initialBody == updatedBody {
 			log.Printf("No update needed for %v, %v", i.Links.Tinyui, page.Title)
 			continue
 		}
-		title := fmt.Sprintf("No update needed for %v, %v", i.Links.Tinyui, page.Title)
-		err = utils.ConditionalOp(title, noop, func() error {
+		if opts.Noop {
+			diff, err := diffBodies(i.ID, initialBody, updatedBody)
+			if err != nil {
+				return err
+			}
+			fmt.Println(diff)
+		}
+		title := fmt.Sprintf("Updating %v, %v", i.Links.Tinyui, page.Title)
+		err = utils.ConditionalOp(title, opts.Noop, func() error {
+			if err := backupPage(backupDir, i.ID, i.Links.Tinyui, page); err != nil {
+				return err
+			}
 			return c.updatePage(i.ID, page, updatedBody)
 		})
 		if err != nil {
 			return err
 		}
+		updated++
+	}
+	if updated > 0 && !opts.Noop {
+		log.Printf("%v page(s) updated. Use 'nub confluence restore %v' to roll back.", updated, backupDir)
 	}
 	return nil
 }
 
-func (c *Confluence) SearchAndOpen(isCQL bool, cql ...string) error {
+type SearchOptions struct {
+	CQL   bool
+	Limit int
+	// Format lists the results as a table, json or yaml instead of opening the page picked.
+	Format string
+}
+
+func (c *Confluence) SearchAndOpen(opts SearchOptions, cql ...string) error {
 	query := strings.Join(cql, " ")
-	if !isCQL {
+	if !opts.CQL {
 		query = fmt.Sprintf("text ~ '%v'", query)
 	}
-	page, err := c.pickPage(c.Search(query))
+	results, err := c.Search(query, opts.Limit)
+	if err != nil {
+		return err
+	}
+	if opts.Format != "" {
+		return utils.PrintStructured(opts.Format, results, searchResultRows(c.cfg.Confluence.Server, results))
+	}
+	page, err := c.pickPage(results)
 	if err != nil {
 		return err
 	}
@@ -461,31 +787,67 @@ func (c *Confluence) pickPage(results []SearchResult) (SearchResult, error) {
 	return results[i], err
 }
 
-func (c *Confluence) Search(cql string) []SearchResult {
-	start := 0
-	limit := 500
-	response := c.search(cql, 0, limit)
-	results := response.Results
-	for response.Size == limit {
-		start += limit
-		response = c.search(cql, start, limit)
+// Search returns the results matching the query, following the pagination. A limit of 0 returns all of them.
+func (c *Confluence) Search(cql string, limit int) ([]SearchResult, error) {
+	qs := map[string]string{
+		"cql":    cql,
+		"start":  "0",
+		"limit":  strconv.Itoa(searchPageSize),
+		"expand": "space",
+	}
+	var results []SearchResult
+	for {
+		response, err := c.search(qs)
+		if err != nil {
+			return nil, err
+		}
 		results = append(results, response.Results...)
+		if limit > 0 && len(results) >= limit {
+			return results[:limit], nil
+		}
+		if response.Links.Next == "" || len(response.Results) == 0 {
+			return results, nil
+		}
+		qs, err = nextSearchQuery(response.Links.Next)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return results
 }
 
-func (c *Confluence) search(cql string, start, limit int) *SearchResults {
-	log.Printf("Searching: %v position: %v", cql, start)
-	result := &SearchResults{}
-	qs := map[string]string{
-		"cql":   cql,
-		"start": strconv.Itoa(start),
-		"limit": strconv.Itoa(limit),
+// nextSearchQuery extracts the query of the next link, it may contain a start position or a cursor.
+func nextSearchQuery(next string) (map[string]string, error) {
+	u, err := url.Parse(next)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the next link %v", next)
+	}
+	qs := map[string]string{}
+	for key, values := range u.Query() {
+		qs[key] = values[0]
 	}
-	_, err := c.client.Res(
-		"content/search", result).Get(qs)
+	return qs, nil
+}
+
+func (c *Confluence) search(qs map[string]string) (*SearchResults, error) {
+	log.Printf("Searching: %v position: %v", qs["cql"], qs["start"])
+	result := &SearchResults{}
+	request, err := c.client.Res("content/search", result).Get(qs)
 	if err != nil {
-		log.Fatalf("Failed to search: %v", err)
+		return nil, errors.Wrap(err, "failed to search")
+	}
+	if request.Raw.StatusCode != 200 {
+		return nil, fmt.Errorf(
+			"confluence REST API returns unexpected HTTP status: %s",
+			request.Raw.Status,
+		)
+	}
+	return result, nil
+}
+
+func searchResultRows(server string, results []SearchResult) [][]string {
+	rows := [][]string{{"ID", "TITLE", "SPACE", "URL"}}
+	for _, r := range results {
+		rows = append(rows, []string{r.ID, r.Title, r.Space.Key, server + r.Links.Tinyui})
 	}
-	return result
+	return rows
 }
@@ -0,0 +1,214 @@
+package atlassian
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/j-martin/nub/utils"
+	"github.com/pkg/errors"
+)
+
+const attachmentHashPrefix = "nub-sha256:"
+
+var (
+	markdownImageRegex = regexp.MustCompile(`(!\[[^\]]*\]\()\s*<?([^)\s>]+)>?((?:\s+"[^"]*")?\s*\))`)
+	htmlImageRegex     = regexp.MustCompile(`<img [^>]*?/?>`)
+	htmlAttributeRegex = regexp.MustCompile(`(src|alt|title)="([^"]*)"`)
+)
+
+type Attachment struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Metadata struct {
+		Comment string `json:"comment"`
+	} `json:"metadata"`
+}
+
+type Attachments struct {
+	Results []Attachment `json:"results"`
+}
+
+func isRelativeURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	return parsed.Scheme == "" && parsed.Host == "" && !strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "#")
+}
+
+// rewriteImagePaths makes the relative image references of a markdown file, located in fileDir, relative to the
+// root of the repository. The rewritten paths are returned decoded, e.g. 'my%20image.png' is 'my image.png'.
+func rewriteImagePaths(markdown []byte, fileDir string) ([]byte, []string) {
+	var images []string
+	rewritten := replaceOutsideCodeBlocks(markdown, func(chunk []byte) []byte {
+		return markdownImageRegex.ReplaceAllFunc(chunk, func(match []byte) []byte {
+			groups := markdownImageRegex.FindSubmatch(match)
+			target := string(groups[2])
+			if !isRelativeURL(target) {
+				return match
+			}
+			decoded, err := url.PathUnescape(target)
+			if err != nil {
+				decoded = target
+			}
+			imagePath := path.Join(fileDir, decoded)
+			if strings.HasPrefix(imagePath, "../") {
+				return match
+			}
+			images = append(images, imagePath)
+			return []byte(string(groups[1]) + encodeImagePath(imagePath) + string(groups[3]))
+		})
+	})
+	return rewritten, images
+}
+
+// encodeImagePath escapes the path for the markdown links, e.g. the spaces.
+func encodeImagePath(imagePath string) string {
+	return strings.TrimPrefix((&url.URL{Path: imagePath}).EscapedPath(), "./")
+}
+
+// attachmentName flattens the path of the image since attachment names cannot contain slashes.
+func attachmentName(imagePath string) string {
+	return strings.Replace(imagePath, "/", "_", -1)
+}
+
+// replaceImagesWithAttachments replaces the <img> tags referencing the images by attachment macros.
+func replaceImagesWithAttachments(content []byte, images []string) []byte {
+	known := map[string]bool{}
+	for _, i := range images {
+		known[i] = true
+	}
+	return htmlImageRegex.ReplaceAllFunc(content, func(tag []byte) []byte {
+		attributes := map[string]string{}
+		for _, a := range htmlAttributeRegex.FindAllSubmatch(tag, -1) {
+			attributes[string(a[1])] = html.UnescapeString(string(a[2]))
+		}
+		src, err := url.PathUnescape(attributes["src"])
+		if err != nil {
+			src = attributes["src"]
+		}
+		if !known[src] {
+			return tag
+		}
+		macro := "<ac:image"
+		if alt := attributes["alt"]; alt != "" {
+			macro += ` ac:alt="` + html.EscapeString(alt) + `"`
+		}
+		if title := attributes["title"]; title != "" {
+			macro += ` ac:title="` + html.EscapeString(title) + `"`
+		}
+		return []byte(macro + `><ri:attachment ri:filename="` + html.EscapeString(attachmentName(src)) + `" /></ac:image>`)
+	})
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return attachmentHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// uploadAttachments uploads the images as attachments of the page. Images whose hash did not change since the
+// last upload are skipped.
+func (c *Confluence) uploadAttachments(pageID, repoDir string, images []string, dryRun bool) error {
+	for _, imagePath := range utils.RemoveDuplicatesUnordered(images) {
+		content, err := ioutil.ReadFile(path.Join(repoDir, imagePath))
+		if err != nil {
+			return err
+		}
+		name := attachmentName(imagePath)
+		hash := hashContent(content)
+		existing, err := c.getAttachment(pageID, name)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.Metadata.Comment == hash {
+			log.Printf("%v unchanged. Skipping upload.", imagePath)
+			continue
+		}
+		uri := "content/" + pageID + "/child/attachment"
+		if existing != nil {
+			uri = uri + "/" + existing.ID + "/data"
+		}
+		err = utils.ConditionalOp(fmt.Sprintf("Uploading %v as %v.", imagePath, name), dryRun, func() error {
+			return c.postAttachment(uri, name, content, hash)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Confluence) getAttachment(pageID, name string) (*Attachment, error) {
+	request, err := c.client.Res(
+		"content/"+pageID+"/child/attachment", &Attachments{},
+	).Get(map[string]string{"filename": name, "expand": "metadata"})
+	if err != nil {
+		return nil, err
+	}
+	if request.Raw.StatusCode != 200 {
+		return nil, fmt.Errorf(
+			"confluence REST API returns unexpected HTTP status: %s",
+			request.Raw.Status,
+		)
+	}
+	attachments := request.Response.(*Attachments)
+	for _, a := range attachments.Results {
+		if a.Title == name {
+			return &a, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *Confluence) postAttachment(uri, name string, content []byte, comment string) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		return err
+	}
+	if _, err = part.Write(content); err != nil {
+		return err
+	}
+	if err = writer.WriteField("comment", comment); err != nil {
+		return err
+	}
+	if err = writer.WriteField("minorEdit", "true"); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", c.cfg.Confluence.Server+"/rest/api/"+uri, &body)
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(c.cfg.Confluence.Username, c.cfg.Confluence.Password)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	request.Header.Set("X-Atlassian-Token", "nocheck")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		output, _ := ioutil.ReadAll(response.Body)
+		return errors.Errorf(
+			"confluence REST API returns unexpected HTTP status: %s, output: %s",
+			response.Status, output,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package atlassian
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/j-martin/nub/core"
+	"github.com/j-martin/nub/utils"
+	"github.com/pkg/errors"
+)
+
+type PageBackup struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Version int64  `json:"version"`
+	URL     string `json:"url"`
+	Body    string `json:"body"`
+}
+
+func getBackupRootDir() string {
+	return path.Join(core.GetConfigPath("backups"), "confluence")
+}
+
+// NewBackupDir returns a new directory in which the pages are saved before being modified.
+func NewBackupDir() string {
+	return path.Join(getBackupRootDir(), utils.CurrentTimeForFilename())
+}
+
+func backupPage(backupDir, pageID, url string, page PageInfo) error {
+	err := os.MkdirAll(backupDir, 0700)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(PageBackup{
+		ID:      pageID,
+		Title:   page.Title,
+		Version: page.Version.Number,
+		URL:     url,
+		Body:    page.Body.Storage.Value,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	backupFile := path.Join(backupDir, pageID+".json")
+	log.Printf("Saving backup of %v to %v", pageID, backupFile)
+	return ioutil.WriteFile(backupFile, data, 0600)
+}
+
+func loadBackups(backupPath string) (backups []PageBackup, err error) {
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{backupPath}
+	if info.IsDir() {
+		files = nil
+		entries, err := ioutil.ReadDir(backupPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if path.Ext(e.Name()) == ".json" {
+				files = append(files, path.Join(backupPath, e.Name()))
+			}
+		}
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		backup := PageBackup{}
+		if err := json.Unmarshal(data, &backup); err != nil {
+			return nil, errors.Wrapf(err, "failed to load %v", f)
+		}
+		backups = append(backups, backup)
+	}
+	return backups, nil
+}
+
+func pickBackupDir() (string, error) {
+	entries, err := ioutil.ReadDir(getBackupRootDir())
+	if os.IsNotExist(err) || err == nil && len(entries) == 0 {
+		return "", errors.New("no backup found in " + getBackupRootDir())
+	}
+	if err != nil {
+		return "", err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, e.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	dir, err := utils.PickItem("Pick a backup", dirs)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(getBackupRootDir(), dir), nil
+}
+
+// RestorePages restores the pages saved in a backup directory or file. If no path is passed, the backup is picked
+// from the existing ones.
+func (c *Confluence) RestorePages(backupPath string, noop bool) error {
+	if backupPath == "" {
+		var err error
+		backupPath, err = pickBackupDir()
+		if err != nil {
+			return err
+		}
+	}
+	backups, err := loadBackups(backupPath)
+	if err != nil {
+		return err
+	}
+	backupDir := NewBackupDir()
+	for _, b := range backups {
+		page, err := c.getPageInfo(b.ID)
+		if err != nil {
+			return err
+		}
+		if page.Body.Storage.Value == b.Body && page.Title == b.Title {
+			log.Printf("No restore needed for %v, %v", b.URL, b.Title)
+			continue
+		}
+		if noop {
+			diff, err := diffBodies(b.ID, page.Body.Storage.Value, b.Body)
+			if err != nil {
+				return err
+			}
+			fmt.Println(diff)
+		}
+		message := fmt.Sprintf("Restoring %v, %v to version %v (current: %v)", b.URL, b.Title, b.Version, page.Version.Number)
+		err = utils.ConditionalOp(message, noop, func() error {
+			if err := backupPage(backupDir, b.ID, b.URL, page); err != nil {
+				return err
+			}
+			page.Title = b.Title
+			return c.updatePage(b.ID, page, b.Body)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,479 @@
+package atlassian
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/j-martin/nub/utils"
+	"github.com/pkg/errors"
+)
+
+var (
+	whitespaceRegex   = regexp.MustCompile(`\s+`)
+	fileNameRegex     = regexp.MustCompile(`[^\pL\pN._-]+`)
+	blankLinesRegex   = regexp.MustCompile(`\n{3,}`)
+	calloutMacroNames = map[string]string{"info": "Info", "note": "Note", "warning": "Warning", "tip": "Tip"}
+	inlineElements    = map[string]bool{
+		"a": true, "b": true, "strong": true, "i": true, "em": true, "code": true, "s": true, "del": true,
+		"u": true, "span": true, "sub": true, "sup": true, "br": true, "img": true, "ac:link": true,
+		"ac:image": true, "ac:emoticon": true, "time": true,
+	}
+)
+
+type ExportOptions struct {
+	CQL       bool
+	Limit     int
+	OutputDir string
+	Noop      bool
+}
+
+// ExportPages converts the pages matching the query, or the page ID, to markdown. The files are written in a
+// directory tree mirroring the ancestors of the pages.
+func (c *Confluence) ExportPages(query string, opts ExportOptions) error {
+	pageIDs := []string{query}
+	if opts.CQL {
+		results, err := c.Search(query, opts.Limit)
+		if err != nil {
+			return err
+		}
+		pageIDs = nil
+		for _, r := range results {
+			pageIDs = append(pageIDs, r.ID)
+		}
+	}
+	if len(pageIDs) == 0 {
+		return errors.New("no page to export")
+	}
+	for _, pageID := range pageIDs {
+		page, err := c.getPageInfo(pageID)
+		if err != nil {
+			return err
+		}
+		markdown, err := storageToMarkdown(page.Body.Storage.Value)
+		if err != nil {
+			return errors.Wrapf(err, "failed to convert %v, %v", pageID, page.Title)
+		}
+		filePath := path.Join(opts.OutputDir, exportPath(page))
+		err = utils.ConditionalOp(fmt.Sprintf("Exporting %v, %v to %v", pageID, page.Title, filePath), opts.Noop, func() error {
+			if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+				return err
+			}
+			return ioutil.WriteFile(filePath, []byte("# "+page.Title+"\n\n"+markdown), 0644)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Printf("%v page(s) exported.", len(pageIDs))
+	return nil
+}
+
+func exportFileName(title string) string {
+	name := strings.Trim(fileNameRegex.ReplaceAllString(title, "-"), "-")
+	if name == "" {
+		return "untitled"
+	}
+	return name
+}
+
+// exportPath returns the path of the page, e.g. Parent/Child/Page.md.
+func exportPath(page PageInfo) string {
+	var elements []string
+	for _, a := range page.Ancestors {
+		elements = append(elements, exportFileName(a.Title))
+	}
+	return path.Join(append(elements, exportFileName(page.Title)+".md")...)
+}
+
+type storageNode struct {
+	name     string
+	attrs    map[string]string
+	text     string
+	children []*storageNode
+}
+
+func (n *storageNode) isText() bool {
+	return n.name == ""
+}
+
+func (n *storageNode) child(name string) *storageNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// parameter returns the value of a macro parameter, e.g. the language of a code block.
+func (n *storageNode) parameter(name string) string {
+	for _, c := range n.children {
+		if c.name == "ac:parameter" && c.attrs["ac:name"] == name {
+			return c.textContent()
+		}
+	}
+	return ""
+}
+
+func (n *storageNode) textContent() string {
+	if n.isText() {
+		return n.text
+	}
+	var content string
+	for _, c := range n.children {
+		content += c.textContent()
+	}
+	return content
+}
+
+func xmlName(name xml.Name) string {
+	if name.Space != "" {
+		return strings.ToLower(name.Space + ":" + name.Local)
+	}
+	return strings.ToLower(name.Local)
+}
+
+// parseStorage parses the storage format, which is XHTML with Confluence specific elements, e.g. ac:structured-macro.
+func parseStorage(storage string) (*storageNode, error) {
+	decoder := xml.NewDecoder(strings.NewReader("<root>" + storage + "</root>"))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	root := &storageNode{name: "root"}
+	stack := []*storageNode{root}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return root, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		current := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &storageNode{name: xmlName(t.Name), attrs: map[string]string{}}
+			for _, a := range t.Attr {
+				node.attrs[xmlName(a.Name)] = a.Value
+			}
+			current.children = append(current.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			current.children = append(current.children, &storageNode{text: string(t)})
+		}
+	}
+}
+
+// storageToMarkdown converts the storage format of a page to markdown.
+func storageToMarkdown(storage string) (string, error) {
+	root, err := parseStorage(storage)
+	if err != nil {
+		return "", err
+	}
+	markdown := blankLinesRegex.ReplaceAllString(renderBlocks(root.children), "\n\n")
+	return strings.TrimSpace(markdown) + "\n", nil
+}
+
+func isInline(n *storageNode) bool {
+	return n.isText() || inlineElements[n.name]
+}
+
+func renderBlocks(nodes []*storageNode) string {
+	var output string
+	var inline []*storageNode
+	flush := func() {
+		if text := strings.TrimSpace(renderInline(inline)); text != "" {
+			output += text + "\n\n"
+		}
+		inline = nil
+	}
+	for _, n := range nodes {
+		if isInline(n) {
+			inline = append(inline, n)
+			continue
+		}
+		flush()
+		output += renderBlock(n)
+	}
+	flush()
+	return output
+}
+
+func renderBlock(n *storageNode) string {
+	switch n.name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.name[1] - '0')
+		return strings.Repeat("#", level) + " " + strings.TrimSpace(renderInline(n.children)) + "\n\n"
+	case "p":
+		return strings.TrimSpace(renderInline(n.children)) + "\n\n"
+	case "hr":
+		return "---\n\n"
+	case "pre":
+		return fence(n.textContent(), "")
+	case "blockquote":
+		return quote(renderBlocks(n.children))
+	case "ul", "ol":
+		return renderList(n, "") + "\n"
+	case "table":
+		return renderTable(n)
+	case "ac:structured-macro":
+		return renderMacro(n)
+	case "ac:task-list":
+		var output string
+		for _, task := range n.children {
+			if task.name != "ac:task" {
+				continue
+			}
+			checkbox := "[ ]"
+			if status := task.child("ac:task-status"); status != nil && status.textContent() == "complete" {
+				checkbox = "[x]"
+			}
+			body := ""
+			if b := task.child("ac:task-body"); b != nil {
+				body = strings.TrimSpace(renderInline(b.children))
+			}
+			output += "- " + checkbox + " " + body + "\n"
+		}
+		return output + "\n"
+	case "ac:parameter", "ac:placeholder":
+		return ""
+	}
+	return renderBlocks(n.children)
+}
+
+func renderMacro(n *storageNode) string {
+	name := n.attrs["ac:name"]
+	body := n.child("ac:rich-text-body")
+	switch name {
+	case "code", "noformat":
+		if b := n.child("ac:plain-text-body"); b != nil {
+			return fence(b.textContent(), n.parameter("language"))
+		}
+		return ""
+	case "info", "note", "warning", "tip":
+		content := "**" + calloutMacroNames[name] + ":**"
+		if title := n.parameter("title"); title != "" {
+			content += " " + title
+		}
+		content += "\n\n"
+		if body != nil {
+			content += renderBlocks(body.children)
+		}
+		return quote(content)
+	case "expand":
+		title := n.parameter("title")
+		if title == "" {
+			title = "Click here to expand..."
+		}
+		content := "<details>\n<summary>" + title + "</summary>\n\n"
+		if body != nil {
+			content += renderBlocks(body.children)
+		}
+		return content + "</details>\n\n"
+	case "anchor", "toc", "children", "pagetree":
+		return ""
+	}
+	if body != nil {
+		return renderBlocks(body.children)
+	}
+	return ""
+}
+
+func fence(code, language string) string {
+	return "```" + language + "\n" + strings.Trim(code, "\n") + "\n```\n\n"
+}
+
+func quote(content string) string {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight("> "+l, " ")
+	}
+	return strings.Join(lines, "\n") + "\n\n"
+}
+
+func renderList(n *storageNode, indent string) string {
+	var output string
+	index := 0
+	for _, item := range n.children {
+		if item.name != "li" {
+			continue
+		}
+		index++
+		marker := "- "
+		if n.name == "ol" {
+			marker = fmt.Sprintf("%v. ", index)
+		}
+		var inline []*storageNode
+		var nested string
+		for _, c := range item.children {
+			switch {
+			case c.name == "ul" || c.name == "ol":
+				nested += renderList(c, indent+strings.Repeat(" ", len(marker)))
+			case c.name == "p":
+				inline = append(inline, c.children...)
+			case isInline(c):
+				inline = append(inline, c)
+			default:
+				nested += indentLines(renderBlock(c), indent+strings.Repeat(" ", len(marker)))
+			}
+		}
+		output += indent + marker + strings.TrimSpace(renderInline(inline)) + "\n" + nested
+	}
+	return output
+}
+
+func indentLines(content, indent string) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = indent + l
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func renderTable(n *storageNode) string {
+	var rows [][]string
+	var collect func(*storageNode)
+	collect = func(node *storageNode) {
+		for _, c := range node.children {
+			switch c.name {
+			case "tr":
+				var cells []string
+				for _, cell := range c.children {
+					if cell.name == "th" || cell.name == "td" {
+						text := strings.TrimSpace(renderInline(flattenBlocks(cell.children)))
+						cells = append(cells, strings.Replace(text, "|", `\|`, -1))
+					}
+				}
+				rows = append(rows, cells)
+			case "thead", "tbody", "tfoot":
+				collect(c)
+			}
+		}
+	}
+	collect(n)
+	if len(rows) == 0 {
+		return ""
+	}
+	width := 0
+	for _, r := range rows {
+		if len(r) > width {
+			width = len(r)
+		}
+	}
+	var output string
+	for i, r := range rows {
+		for len(r) < width {
+			r = append(r, "")
+		}
+		output += "| " + strings.Join(r, " | ") + " |\n"
+		if i == 0 {
+			output += strings.Repeat("| --- ", width) + "|\n"
+		}
+	}
+	return output + "\n"
+}
+
+// flattenBlocks keeps the inline content of the blocks since table cells cannot contain blocks in markdown.
+func flattenBlocks(nodes []*storageNode) []*storageNode {
+	var flattened []*storageNode
+	for _, n := range nodes {
+		if isInline(n) {
+			flattened = append(flattened, n)
+			continue
+		}
+		if len(flattened) > 0 {
+			flattened = append(flattened, &storageNode{name: "br"})
+		}
+		flattened = append(flattened, flattenBlocks(n.children)...)
+	}
+	return flattened
+}
+
+func wrapInline(marker string, n *storageNode) string {
+	content := renderInline(n.children)
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return content
+	}
+	return strings.Replace(content, trimmed, marker+trimmed+marker, 1)
+}
+
+func renderInline(nodes []*storageNode) string {
+	var output string
+	for _, n := range nodes {
+		if n.isText() {
+			output += whitespaceRegex.ReplaceAllString(n.text, " ")
+			continue
+		}
+		switch n.name {
+		case "strong", "b":
+			output += wrapInline("**", n)
+		case "em", "i":
+			output += wrapInline("*", n)
+		case "s", "del":
+			output += wrapInline("~~", n)
+		case "code":
+			output += "`" + n.textContent() + "`"
+		case "br":
+			output += "  \n"
+		case "a":
+			output += "[" + strings.TrimSpace(renderInline(n.children)) + "](" + n.attrs["href"] + ")"
+		case "img":
+			output += "![" + n.attrs["alt"] + "](" + n.attrs["src"] + ")"
+		case "ac:image":
+			output += renderImage(n)
+		case "ac:link":
+			output += renderLink(n)
+		case "ac:emoticon":
+			continue
+		default:
+			output += renderInline(n.children)
+		}
+	}
+	return output
+}
+
+func renderImage(n *storageNode) string {
+	src := ""
+	if a := n.child("ri:attachment"); a != nil {
+		src = a.attrs["ri:filename"]
+	} else if u := n.child("ri:url"); u != nil {
+		src = u.attrs["ri:value"]
+	}
+	return "![" + n.attrs["ac:alt"] + "](" + src + ")"
+}
+
+// renderLink renders the links to anchors, the links to other pages are kept as text since the pages may not be
+// exported.
+func renderLink(n *storageNode) string {
+	text := ""
+	if b := n.child("ac:link-body"); b != nil {
+		text = strings.TrimSpace(renderInline(b.children))
+	} else if b := n.child("ac:plain-text-link-body"); b != nil {
+		text = strings.TrimSpace(b.textContent())
+	}
+	if page := n.child("ri:page"); page != nil && text == "" {
+		text = page.attrs["ri:content-title"]
+	}
+	if anchor := n.attrs["ac:anchor"]; anchor != "" {
+		if text == "" {
+			text = anchor
+		}
+		return "[" + text + "](#" + anchor + ")"
+	}
+	return text
+}
@@ -0,0 +1,134 @@
+package atlassian
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	anchorLinkPrefix = "#nub-anchor-"
+	anchorMarker     = "nub-anchor:"
+	readmeFile       = "README.md"
+)
+
+var (
+	markdownLinkRegex          = regexp.MustCompile(`(!?\[[^\]]*\]\()\s*<?([^)\s>]+)>?((?:\s+"[^"]*")?\s*\))`)
+	markdownLinkReferenceRegex = regexp.MustCompile(`(?m)^( {0,3}\[[^\]]+\]:\s*)<?(\S+?)>?((?:\s+.*)?)$`)
+	htmlAnchorLinkRegex        = regexp.MustCompile(`<a href="` + anchorLinkPrefix + `([^"]+)"[^>]*>(.*?)</a>`)
+	htmlAnchorMarkerRegex      = regexp.MustCompile(`<p>` + anchorMarker + `([^<]+)</p>`)
+	markdownHeadingRegex       = regexp.MustCompile(`(?m)^ {0,3}#{1,6}\s+(.+?)[\s#]*$`)
+	markdownFenceRegex         = regexp.MustCompile("^ {0,3}(```|~~~)")
+	headingSlugRegex           = regexp.MustCompile(`[^\p{L}\p{N}_\- ]`)
+	anchorNameRegex            = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+)
+
+// anchorName returns the name of the anchor of a file included in the page, e.g. docs/setup.md -> docs-setup-md.
+func anchorName(filePath string) string {
+	return strings.Trim(anchorNameRegex.ReplaceAllString(filePath, "-"), "-")
+}
+
+// anchorMarkdown adds a marker that is replaced by an anchor macro once the page is rendered.
+func anchorMarkdown(filePath string) string {
+	return "\n\n" + anchorMarker + anchorName(filePath) + "\n\n"
+}
+
+// headingAnchorName returns the name of the anchor of a heading of an included file, the heading is identified by
+// its GitHub slug, e.g. docs/setup.md#install -> docs-setup-md-install.
+func headingAnchorName(filePath, slug string) string {
+	return anchorName(filePath) + "-" + anchorName(slug)
+}
+
+// headingSlug returns the GitHub anchor of the heading, e.g. 'Set up & Run' -> 'set-up--run'.
+func headingSlug(heading string) string {
+	return strings.Replace(headingSlugRegex.ReplaceAllString(strings.ToLower(heading), ""), " ", "-", -1)
+}
+
+// replaceOutsideCodeBlocks applies the replacement to the markdown outside of the fenced code blocks.
+func replaceOutsideCodeBlocks(markdown []byte, replace func([]byte) []byte) []byte {
+	var result, chunk []string
+	fence := ""
+	flush := func() {
+		if len(chunk) > 0 {
+			result = append(result, string(replace([]byte(strings.Join(chunk, "\n")))))
+			chunk = nil
+		}
+	}
+	for _, line := range strings.Split(string(markdown), "\n") {
+		match := markdownFenceRegex.FindStringSubmatch(line)
+		switch {
+		case fence != "":
+			result = append(result, line)
+			if match != nil && match[1] == fence {
+				fence = ""
+			}
+		case match != nil:
+			flush()
+			fence = match[1]
+			result = append(result, line)
+		default:
+			chunk = append(chunk, line)
+		}
+	}
+	flush()
+	return []byte(strings.Join(result, "\n"))
+}
+
+// addHeadingAnchors adds an anchor marker before the headings of the file so that the links to them, from the other
+// files included in the page, keep working.
+func addHeadingAnchors(markdown []byte, filePath string) []byte {
+	return replaceOutsideCodeBlocks(markdown, func(chunk []byte) []byte {
+		return markdownHeadingRegex.ReplaceAllFunc(chunk, func(match []byte) []byte {
+			heading := string(markdownHeadingRegex.FindSubmatch(match)[1])
+			return append([]byte("\n"+anchorMarker+headingAnchorName(filePath, headingSlug(heading))+"\n\n"), match...)
+		})
+	})
+}
+
+// rewriteLinks rewrites the relative links of a markdown file, located in fileDir. Links to files included in the
+// page point to their anchor, the others point to GitHub.
+func rewriteLinks(markdown []byte, fileDir string, included map[string]bool, gitHubURL func(string) string) []byte {
+	rewrite := func(target string) string {
+		if !isRelativeURL(target) {
+			return target
+		}
+		filePath, fragment := target, ""
+		if pos := strings.Index(target, "#"); pos >= 0 {
+			filePath, fragment = target[:pos], target[pos:]
+		}
+		filePath = path.Join(fileDir, filePath)
+		if strings.HasPrefix(filePath, "../") {
+			return target
+		}
+		if included[filePath] && len(fragment) > 1 {
+			return anchorLinkPrefix + headingAnchorName(filePath, fragment[1:])
+		}
+		if included[filePath] {
+			return anchorLinkPrefix + anchorName(filePath)
+		}
+		return gitHubURL(filePath) + fragment
+	}
+	return replaceOutsideCodeBlocks(markdown, func(chunk []byte) []byte {
+		chunk = markdownLinkRegex.ReplaceAllFunc(chunk, func(match []byte) []byte {
+			groups := markdownLinkRegex.FindSubmatch(match)
+			if strings.HasPrefix(string(groups[1]), "!") {
+				return match
+			}
+			return []byte(string(groups[1]) + rewrite(string(groups[2])) + string(groups[3]))
+		})
+		return markdownLinkReferenceRegex.ReplaceAllFunc(chunk, func(match []byte) []byte {
+			groups := markdownLinkReferenceRegex.FindSubmatch(match)
+			return []byte(string(groups[1]) + rewrite(string(groups[2])) + string(groups[3]))
+		})
+	})
+}
+
+// replaceAnchors replaces the anchor markers and the links to them with the Confluence macros.
+func replaceAnchors(content []byte) []byte {
+	content = htmlAnchorMarkerRegex.ReplaceAll(content, []byte(
+		`<ac:structured-macro ac:name="anchor" ac:schema-version="1"><ac:parameter ac:name="">$1</ac:parameter></ac:structured-macro>`,
+	))
+	return htmlAnchorLinkRegex.ReplaceAll(content, []byte(
+		`<ac:link ac:anchor="$1"><ac:link-body>$2</ac:link-body></ac:link>`,
+	))
+}
@@ -0,0 +1,190 @@
+package atlassian
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/bndr/gopencils"
+	"github.com/j-martin/nub/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteImagePaths(t *testing.T) {
+	t.Parallel()
+	markdown := `
+![architecture](arch.png "Architecture")
+![remote](https://example.com/logo.png)
+![up](../../outside.png)
+![nested](./img/flow.svg)
+![spaces](my%20diagram.png)
+`
+	rewritten, images := rewriteImagePaths([]byte(markdown), "docs")
+	assert.Equal(t, []string{"docs/arch.png", "docs/img/flow.svg", "docs/my diagram.png"}, images)
+	assert.Contains(t, string(rewritten), `![architecture](docs/arch.png "Architecture")`)
+	assert.Contains(t, string(rewritten), `![remote](https://example.com/logo.png)`)
+	assert.Contains(t, string(rewritten), `![nested](docs/img/flow.svg)`)
+	assert.Contains(t, string(rewritten), `![spaces](docs/my%20diagram.png)`)
+}
+
+func TestReplaceImagesWithAttachments(t *testing.T) {
+	t.Parallel()
+	content := `<p><img src="docs/arch.png" alt="arch &amp; more" /> <img src="https://example.com/logo.png" alt="logo" /> <img src="docs/my%20diagram.png" /></p>`
+	assert.Equal(t,
+		`<p><ac:image ac:alt="arch &amp; more"><ri:attachment ri:filename="docs_arch.png" /></ac:image> <img src="https://example.com/logo.png" alt="logo" /> `+
+			`<ac:image><ri:attachment ri:filename="docs_my diagram.png" /></ac:image></p>`,
+		string(replaceImagesWithAttachments([]byte(content), []string{"docs/arch.png", "docs/my diagram.png"})))
+}
+
+func TestRewriteLinks(t *testing.T) {
+	t.Parallel()
+	included := map[string]bool{"README.md": true, "docs/setup.md": true}
+	gitHubURL := func(filePath string) string {
+		return "https://github.com/org/repo/blob/master/" + filePath
+	}
+	markdown := `
+See [setup](setup.md#install), [readme](../README.md), [script](../scripts/run.sh#L2) and [site](https://example.com).
+![image](arch.png)
+
+[ref]: ./setup.md "Setup"
+
+` + "```" + `
+[readme](../README.md)
+[ref]: ./setup.md
+` + "```" + `
+`
+	assert.Equal(t, `
+See [setup](#nub-anchor-docs-setup-md-install), [readme](#nub-anchor-README-md), [script](https://github.com/org/repo/blob/master/scripts/run.sh#L2) and [site](https://example.com).
+![image](arch.png)
+
+[ref]: #nub-anchor-docs-setup-md "Setup"
+
+`+"```"+`
+[readme](../README.md)
+[ref]: ./setup.md
+`+"```"+`
+`, string(rewriteLinks([]byte(markdown), "docs", included, gitHubURL)))
+}
+
+func TestAddHeadingAnchors(t *testing.T) {
+	t.Parallel()
+	markdown := "intro\n## Install & Run ##\n~~~\n# not a heading\n~~~\n"
+	assert.Equal(t,
+		"intro\n\nnub-anchor:docs-setup-md-install-run\n\n## Install & Run ##\n~~~\n# not a heading\n~~~\n",
+		string(addHeadingAnchors([]byte(markdown), "docs/setup.md")))
+}
+
+func TestReplaceAnchors(t *testing.T) {
+	t.Parallel()
+	content := `<p>nub-anchor:docs-setup-md</p><p><a href="#nub-anchor-docs-setup-md">the <em>setup</em></a></p>`
+	assert.Equal(t,
+		`<ac:structured-macro ac:name="anchor" ac:schema-version="1"><ac:parameter ac:name="">docs-setup-md</ac:parameter></ac:structured-macro>`+
+			`<p><ac:link ac:anchor="docs-setup-md"><ac:link-body>the <em>setup</em></ac:link-body></ac:link></p>`,
+		string(replaceAnchors([]byte(content))))
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "nub-backup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	page := PageInfo{Title: "Page"}
+	page.Version.Number = 3
+	page.Body.Storage.Value = "<p>body</p>"
+	assert.Nil(t, backupPage(dir, "42", "/x/abc", page))
+
+	backups, err := loadBackups(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []PageBackup{{ID: "42", Title: "Page", Version: 3, URL: "/x/abc", Body: "<p>body</p>"}}, backups)
+}
+
+func TestNextSearchQuery(t *testing.T) {
+	t.Parallel()
+	qs, err := nextSearchQuery("/rest/api/content/search?cql=type%3Dpage&limit=100&start=100&expand=space")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"cql": "type=page", "limit": "100", "start": "100", "expand": "space"}, qs)
+}
+
+func TestStorageToMarkdown(t *testing.T) {
+	t.Parallel()
+	storage := `<h2>Setup</h2><p>Run <code>make</code> &amp; <strong>wait</strong>.<br/>Then <a href="https://example.com">see</a>.</p>` +
+		`<ul><li>one<ul><li>nested</li></ul></li><li><p>two</p></li></ul>` +
+		`<ac:structured-macro ac:name="code"><ac:parameter ac:name="language">go</ac:parameter>` +
+		`<ac:plain-text-body><![CDATA[if a > b {
+	return
+}]]></ac:plain-text-body></ac:structured-macro>` +
+		`<ac:structured-macro ac:name="info"><ac:rich-text-body><p>Be careful&nbsp;here.</p></ac:rich-text-body></ac:structured-macro>` +
+		`<ac:structured-macro ac:name="expand"><ac:parameter ac:name="title">Details</ac:parameter>` +
+		`<ac:rich-text-body><p>Hidden</p></ac:rich-text-body></ac:structured-macro>` +
+		`<table><tbody><tr><th>Name</th><th>Value</th></tr><tr><td><p>a|b</p></td><td>2</td></tr></tbody></table>` +
+		`<p><ac:image ac:alt="arch"><ri:attachment ri:filename="arch.png" /></ac:image></p>`
+	markdown, err := storageToMarkdown(storage)
+	assert.Nil(t, err)
+	assert.Equal(t, "## Setup\n\n"+
+		"Run `make` & **wait**.  \nThen [see](https://example.com).\n\n"+
+		"- one\n  - nested\n- two\n\n"+
+		"```go\nif a > b {\n\treturn\n}\n```\n\n"+
+		"> **Info:**\n>\n> Be careful here.\n\n"+
+		"<details>\n<summary>Details</summary>\n\nHidden\n\n</details>\n\n"+
+		"| Name | Value |\n| --- | --- |\n| a\\|b | 2 |\n\n"+
+		"![arch](arch.png)\n", markdown)
+}
+
+func TestExportPath(t *testing.T) {
+	t.Parallel()
+	page := PageInfo{Title: "Deploy / Rollback"}
+	page.Ancestors = append(page.Ancestors, struct {
+		Id    string `json:"id"`
+		Title string `json:"title"`
+	}{Id: "1", Title: "Engineering Home"})
+	assert.Equal(t, "Engineering-Home/Deploy-Rollback.md", exportPath(page))
+}
+
+func TestUpdateDocumentationUploadsChangedImages(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "nub-confluence")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, "diagram.png"), []byte("edited"), 0644))
+
+	cfg := &core.Configuration{}
+	m := &core.Manifest{Name: "test", Readme: "![diagram](diagram.png)\n", Documentation: core.Documentation{PageId: "123"}}
+	c := &Confluence{cfg: cfg}
+	// the page is unchanged, only the image was edited in place.
+	body, _, err := c.createPage(m, dir)
+	assert.Nil(t, err)
+
+	var uploads, pageUpdates int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/rest/api/content/123":
+			page := PageInfo{Title: "Test - Readme"}
+			page.Body.Storage.Value = string(body)
+			json.NewEncoder(w).Encode(page)
+		case r.Method == "GET" && r.URL.Path == "/rest/api/content/123/child/attachment":
+			attachment := Attachment{ID: "att1", Title: "diagram.png"}
+			attachment.Metadata.Comment = hashContent([]byte("original"))
+			json.NewEncoder(w).Encode(Attachments{Results: []Attachment{attachment}})
+		case r.Method == "POST" && r.URL.Path == "/rest/api/content/123/child/attachment/att1/data":
+			uploads++
+			w.Write([]byte("{}"))
+		case r.Method == "PUT":
+			pageUpdates++
+			w.Write([]byte("{}"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	cfg.Confluence.Server = server.URL
+	c.client = gopencils.Api(server.URL+"/rest/api", &gopencils.BasicAuth{})
+
+	assert.Nil(t, c.UpdateDocumentation(m, dir, PublishOptions{}))
+	assert.Equal(t, 1, uploads, "the edited image is uploaded")
+	assert.Equal(t, 0, pageUpdates, "the page is not updated")
+}
@@ -235,17 +235,20 @@ func (j *JIRA) PickAssignedIssue() (*jira.Issue, error) {
 	return j.pickIssue(issues)
 }
 
-func (j *JIRA) CreateBranchFromAssignedIssue() error {
+// CreateBranchFromAssignedIssue creates the branch of the picked issue. Stacked branches are created on top of the
+// current branch instead of the default branch.
+func (j *JIRA) CreateBranchFromAssignedIssue(stacked bool) error {
 	issue, err := j.PickAssignedIssue()
 	if err != nil {
 		return err
 	}
+	if stacked {
+		return core.MustInitGit(".").CreateStackedBranch(issueBranchPrefix(issue) + issue.Fields.Summary)
+	}
 	return j.CreateBranchFromIssue(issue, ".", false)
 }
 
-func (j *JIRA) CreateBranchFromIssue(issue *jira.Issue, repoDir string, forceNewBranch bool) error {
-	git := core.MustInitGit(repoDir)
-	git.Fetch()
+func issueBranchPrefix(issue *jira.Issue) string {
 	prefix := "chore"
 	issueType := issue.Fields.Type.Name
 	if issueType == "Bug" {
@@ -253,8 +256,20 @@ func (j *JIRA) CreateBranchFromIssue(issue *jira.Issue, repoDir string, forceNew
 	} else if issueType == "Story" {
 		prefix = "feat"
 	}
-	prefix = prefix + "/" + issue.Key + "/"
-	err := git.CreateBranch(prefix + issue.Fields.Summary)
+	return prefix + "/" + issue.Key + "/"
+}
+
+// IssueBranchName returns the name of the branch of the issue, e.g. feat/ABC-123/the summary. It is sanitized when
+// the branch is created.
+func (j *JIRA) IssueBranchName(issue *jira.Issue) string {
+	return issueBranchPrefix(issue) + issue.Fields.Summary
+}
+
+func (j *JIRA) CreateBranchFromIssue(issue *jira.Issue, repoDir string, forceNewBranch bool) error {
+	git := core.MustInitGit(repoDir)
+	git.Fetch()
+	prefix := issueBranchPrefix(issue)
+	err := git.CreateBranch(j.IssueBranchName(issue))
 	if err != nil {
 		if forceNewBranch || utils.AskForConfirmation("Failed to create branch. Force/overwrite?") {
 			return git.ForceCreateBranch(prefix + " " + issue.Fields.Summary)
@@ -372,7 +387,7 @@ func (j *JIRA) CreateIssue(project, summary, description, transition string, rea
 		j.logBody(res)
 		return err
 	}
-	log.Printf("%v created. %v", i.Key, strings.TrimRight(j.cfg.JIRA.Server, "/")+"/browse/"+i.Key)
+	log.Printf("%v created. %v", i.Key, j.IssueURL(i.Key))
 	if transition != "" {
 		if err = j.TransitionIssue(i.Key, transition); err != nil {
 			return err
@@ -463,6 +478,19 @@ func (j *JIRA) CommentOnIssue(key, body string) error {
 	return err
 }
 
+func (j *JIRA) GetIssueSummary(key string) (string, error) {
+	i, res, err := j.client.Issue.Get(key, &jira.GetQueryOptions{Fields: "summary"})
+	if err != nil {
+		j.logBody(res)
+		return "", err
+	}
+	return i.Fields.Summary, nil
+}
+
+func (j *JIRA) IssueURL(key string) string {
+	return strings.TrimRight(j.cfg.JIRA.Server, "/") + "/browse/" + key
+}
+
 func (j *JIRA) ViewIssue(key string) error {
 	if key == "" {
 		var err error
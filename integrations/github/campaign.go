@@ -0,0 +1,229 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/google/go-github/github"
+	"github.com/j-martin/nub/core"
+	"github.com/j-martin/nub/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	reviewApproved         = "approved"
+	reviewChangesRequested = "changes requested"
+	reviewPending          = "pending"
+	ciSuccess              = "success"
+	ciPending              = "pending"
+	ciFailure              = "failure"
+	ciNone                 = "none"
+)
+
+// checkRun is a check of the Checks API, e.g. a GitHub Actions job. The client predates the API.
+type checkRun struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+}
+
+type checkRuns struct {
+	TotalCount int        `json:"total_count"`
+	CheckRuns  []checkRun `json:"check_runs"`
+}
+
+type PullRequestStatus struct {
+	core.CampaignPullRequest
+	State     string `json:"state"`
+	Review    string `json:"review"`
+	CI        string `json:"ci"`
+	Mergeable *bool  `json:"mergeable"`
+	pr        *github.PullRequest
+}
+
+// IsReady returns true if the PR is open, approved, green and can be merged.
+func (s PullRequestStatus) IsReady() bool {
+	return s.State == "open" && s.Review == reviewApproved && s.CI == ciSuccess && s.Mergeable != nil && *s.Mergeable
+}
+
+func (s PullRequestStatus) mergeableString() string {
+	if s.Mergeable == nil {
+		return "unknown"
+	}
+	return strconv.FormatBool(*s.Mergeable)
+}
+
+func CampaignRows(statuses []PullRequestStatus) [][]string {
+	rows := [][]string{{"REPOSITORY", "PR", "STATE", "REVIEW", "CI", "MERGEABLE", "URL"}}
+	for _, s := range statuses {
+		rows = append(rows, []string{
+			s.Repository, "#" + strconv.Itoa(s.Number), s.State, s.Review, s.CI, s.mergeableString(), s.URL,
+		})
+	}
+	return rows
+}
+
+// CampaignStatus fetches the state, reviews and CI status of every PR of the campaign.
+func (gh *GitHub) CampaignStatus(c *core.Campaign) ([]PullRequestStatus, error) {
+	ctx := context.Background()
+	var statuses []PullRequestStatus
+	for _, p := range c.PullRequests {
+		pr, _, err := gh.client.PullRequests.Get(ctx, p.Owner, p.Repository, p.Number)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get %v#%v", p.Repository, p.Number)
+		}
+		status := PullRequestStatus{CampaignPullRequest: p, State: pr.GetState(), Mergeable: pr.Mergeable, pr: pr}
+		if pr.GetMerged() {
+			status.State = "merged"
+		}
+		status.Review, err = gh.reviewState(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		combined, _, err := gh.client.Repositories.GetCombinedStatus(ctx, p.Owner, p.Repository, pr.GetHead().GetSHA(), nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the CI status of %v#%v", p.Repository, p.Number)
+		}
+		runs, err := gh.listCheckRuns(ctx, p.Owner, p.Repository, pr.GetHead().GetSHA())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the checks of %v#%v", p.Repository, p.Number)
+		}
+		status.CI = ciState(combined, runs)
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// listCheckRuns lists every check run of the commit, the pages are followed.
+func (gh *GitHub) listCheckRuns(ctx context.Context, owner, repo, ref string) ([]checkRun, error) {
+	var runs []checkRun
+	page := 1
+	for {
+		req, err := gh.client.NewRequest("GET", fmt.Sprintf("repos/%v/%v/commits/%v/check-runs?per_page=100&page=%v", owner, repo, ref, page), nil)
+		if err != nil {
+			return nil, err
+		}
+		var result checkRuns
+		response, err := gh.client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, result.CheckRuns...)
+		if response.NextPage == 0 {
+			return runs, nil
+		}
+		page = response.NextPage
+	}
+}
+
+// ciState combines the commit statuses and the check runs. Any failure fails the PR, then any check still running
+// leaves it pending. The neutral and skipped checks do not block it.
+func ciState(combined *github.CombinedStatus, runs []checkRun) string {
+	if combined.GetTotalCount() == 0 && len(runs) == 0 {
+		return ciNone
+	}
+	state := ciSuccess
+	if combined.GetTotalCount() > 0 {
+		switch combined.GetState() {
+		case "failure", "error":
+			return ciFailure
+		case ciPending:
+			state = ciPending
+		}
+	}
+	for _, r := range runs {
+		if r.Status != "completed" {
+			state = ciPending
+			continue
+		}
+		switch r.Conclusion {
+		case "success", "neutral", "skipped":
+		default:
+			return ciFailure
+		}
+	}
+	return state
+}
+
+// reviewState considers the latest review of every reviewer. A single request for changes blocks the PR.
+func (gh *GitHub) reviewState(ctx context.Context, p core.CampaignPullRequest) (string, error) {
+	reviews, _, err := gh.client.PullRequests.ListReviews(ctx, p.Owner, p.Repository, p.Number, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list the reviews of %v#%v", p.Repository, p.Number)
+	}
+	latest := map[string]string{}
+	for _, r := range reviews {
+		switch r.GetState() {
+		case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+			latest[r.GetUser().GetLogin()] = r.GetState()
+		}
+	}
+	state := reviewPending
+	for _, s := range latest {
+		if s == "CHANGES_REQUESTED" {
+			return reviewChangesRequested, nil
+		}
+		if s == "APPROVED" {
+			state = reviewApproved
+		}
+	}
+	return state, nil
+}
+
+// MergeCampaign merges the PRs that are approved, green and mergeable.
+func (gh *GitHub) MergeCampaign(c *core.Campaign, noop bool) error {
+	statuses, err := gh.CampaignStatus(c)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for _, s := range statuses {
+		if !s.IsReady() {
+			log.Printf("%v#%v not ready: state %v, review %v, CI %v, mergeable %v. Skipping.",
+				s.Repository, s.Number, s.State, s.Review, s.CI, s.mergeableString())
+			continue
+		}
+		err := utils.ConditionalOp(fmt.Sprintf("Merging %v#%v", s.Repository, s.Number), noop, func() error {
+			_, _, err := gh.client.PullRequests.Merge(ctx, s.Owner, s.Repository, s.Number, "", nil)
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to merge %v#%v", s.Repository, s.Number)
+		}
+	}
+	return nil
+}
+
+// CloseCampaign closes the open PRs, deletes their remote branches and forgets the campaign.
+func (gh *GitHub) CloseCampaign(c *core.Campaign, noop bool) error {
+	statuses, err := gh.CampaignStatus(c)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for _, s := range statuses {
+		if s.State != "open" {
+			log.Printf("%v#%v is %v. Skipping.", s.Repository, s.Number, s.State)
+			continue
+		}
+		err := utils.ConditionalOp(fmt.Sprintf("Closing %v#%v", s.Repository, s.Number), noop, func() error {
+			closed := "closed"
+			_, _, err := gh.client.PullRequests.Edit(ctx, s.Owner, s.Repository, s.Number, &github.PullRequest{State: &closed})
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to close %v#%v", s.Repository, s.Number)
+		}
+		head := s.pr.GetHead()
+		err = utils.ConditionalOp(fmt.Sprintf("Deleting the branch %v of %v", head.GetRef(), head.GetRepo().GetFullName()), noop, func() error {
+			_, err := gh.client.Git.DeleteRef(ctx, head.GetRepo().GetOwner().GetLogin(), head.GetRepo().GetName(), "heads/"+head.GetRef())
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete the branch of %v#%v", s.Repository, s.Number)
+		}
+	}
+	return utils.ConditionalOp(fmt.Sprintf("Removing the campaign %v", c.Branch), noop, c.Delete)
+}
@@ -0,0 +1,80 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/j-martin/nub/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPullRequestStatusIsReady(t *testing.T) {
+	yes, no := true, false
+	ready := PullRequestStatus{State: "open", Review: reviewApproved, CI: ciSuccess, Mergeable: &yes}
+	assert.True(t, ready.IsReady())
+
+	for _, s := range []PullRequestStatus{
+		{State: "merged", Review: reviewApproved, CI: ciSuccess, Mergeable: &yes},
+		{State: "open", Review: reviewPending, CI: ciSuccess, Mergeable: &yes},
+		{State: "open", Review: reviewApproved, CI: "failure", Mergeable: &yes},
+		{State: "open", Review: reviewApproved, CI: ciSuccess, Mergeable: &no},
+		{State: "open", Review: reviewApproved, CI: ciSuccess},
+	} {
+		assert.False(t, s.IsReady(), "%+v", s)
+	}
+}
+
+func TestCampaignRows(t *testing.T) {
+	status := PullRequestStatus{
+		CampaignPullRequest: core.CampaignPullRequest{Repository: "users", Number: 12, URL: "https://github.com/org/users/pull/12"},
+		State:               "open", Review: reviewPending, CI: ciNone,
+	}
+	assert.Equal(t, [][]string{
+		{"REPOSITORY", "PR", "STATE", "REVIEW", "CI", "MERGEABLE", "URL"},
+		{"users", "#12", "open", "pending", "none", "unknown", "https://github.com/org/users/pull/12"},
+	}, CampaignRows([]PullRequestStatus{status}))
+}
+
+func TestCIState(t *testing.T) {
+	status := func(state string, count int) *github.CombinedStatus {
+		return &github.CombinedStatus{State: &state, TotalCount: &count}
+	}
+	success := checkRun{Status: "completed", Conclusion: "success"}
+	assert.Equal(t, ciNone, ciState(status("pending", 0), nil))
+	assert.Equal(t, ciSuccess, ciState(status("pending", 0), []checkRun{success, {Status: "completed", Conclusion: "skipped"}}))
+	assert.Equal(t, ciSuccess, ciState(status("success", 1), nil))
+	assert.Equal(t, ciPending, ciState(status("success", 1), []checkRun{success, {Status: "in_progress"}}))
+	assert.Equal(t, ciFailure, ciState(status("pending", 1), []checkRun{{Status: "completed", Conclusion: "timed_out"}}))
+	assert.Equal(t, ciFailure, ciState(status("error", 1), []checkRun{success}))
+}
+
+func TestListCheckRunsPages(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/org/users/commits/abc/check-runs", r.URL.Path)
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"total_count": 2, "check_runs": [{"name": "lint", "status": "completed", "conclusion": "failure"}]}`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%v%v?per_page=100&page=2>; rel="next", <%v%v?per_page=100&page=2>; rel="last"`,
+			server.URL, r.URL.Path, server.URL, r.URL.Path))
+		fmt.Fprint(w, `{"total_count": 2, "check_runs": [{"name": "test", "status": "completed", "conclusion": "success"}]}`)
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	assert.Nil(t, err)
+	client.BaseURL = baseURL
+
+	runs, err := InitGitHubWithClient(&core.Configuration{}, client).listCheckRuns(context.Background(), "org", "users", "abc")
+	assert.Nil(t, err)
+	assert.Equal(t, []checkRun{
+		{Name: "test", Status: "completed", Conclusion: "success"},
+		{Name: "lint", Status: "completed", Conclusion: "failure"},
+	}, runs)
+}
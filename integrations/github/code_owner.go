@@ -6,13 +6,14 @@ import (
 	"io/ioutil"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 type OwnerMap map[string][]string
 
-func (gh *GitHub) PopulateOwners(m *core.Manifest) error {
-	owners, err := gh.ListCodeOwners()
+func (gh *GitHub) PopulateOwners(g *core.Git, m *core.Manifest) error {
+	owners, err := gh.ListCodeOwners(g)
 	if err != nil {
 		return err
 	}
@@ -20,8 +21,9 @@ func (gh *GitHub) PopulateOwners(m *core.Manifest) error {
 	return nil
 }
 
-func (gh *GitHub) ListCodeOwners() (core.Ownership, error) {
-	owners, err := gh.GetCodeOwners()
+// ListCodeOwners returns the owners of the CODEOWNERS of the repository.
+func (gh *GitHub) ListCodeOwners(g *core.Git) (core.Ownership, error) {
+	owners, err := gh.GetCodeOwners(g)
 	if err != nil {
 		return nil, err
 	}
@@ -46,14 +48,15 @@ func (gh *GitHub) ListCodeOwners() (core.Ownership, error) {
 
 type Reviewers []string
 
-func (gh *GitHub) ListReviewers() (reviewers Reviewers, err error) {
+// ListReviewers returns the configured reviewers and the owners of the files changed in the repository.
+func (gh *GitHub) ListReviewers(g *core.Git) (reviewers Reviewers, err error) {
 	reviewers = gh.cfg.GitHub.Reviewers
-	owners, err := gh.ListCodeOwners()
+	owners, err := gh.ListCodeOwners(g)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, filename := range core.MustInitGit("").ListFileChanged() {
+	for _, filename := range g.ListFileChanged() {
 		for rule, o := range owners {
 			if matchesCodeOwnerRules(rule, filename) {
 				for _, owner := range o {
@@ -68,6 +71,29 @@ func (gh *GitHub) ListReviewers() (reviewers Reviewers, err error) {
 	return utils.RemoveDuplicatesUnordered(reviewers), nil
 }
 
+// FileOwners returns the CODEOWNERS owners of every file, files without owners are omitted.
+func (gh *GitHub) FileOwners(g *core.Git, files []string) (map[string][]string, error) {
+	owners, err := gh.GetCodeOwners(g)
+	if err != nil {
+		return nil, err
+	}
+	fileOwners := make(map[string][]string)
+	for _, filename := range files {
+		var o []string
+		for rule, ruleOwners := range owners {
+			if matchesCodeOwnerRules(rule, filename) {
+				o = append(o, ruleOwners...)
+			}
+		}
+		if len(o) > 0 {
+			o = utils.RemoveDuplicatesUnordered(o)
+			sort.Strings(o)
+			fileOwners[filename] = o
+		}
+	}
+	return fileOwners, nil
+}
+
 func matchesCodeOwnerRules(rule, filename string) bool {
 	if rule == "*" {
 		return true
@@ -82,8 +108,9 @@ func matchesCodeOwnerRules(rule, filename string) bool {
 	return strings.HasPrefix(filename, rule)
 }
 
-func (gh *GitHub) GetCodeOwners() (owners OwnerMap, err error) {
-	repo, err := core.MustInitGit("").GetRepositoryRootPath()
+// GetCodeOwners reads the CODEOWNERS of the repository, at the root, in .github or in docs.
+func (gh *GitHub) GetCodeOwners(g *core.Git) (owners OwnerMap, err error) {
+	repo, err := g.GetRepositoryRootPath()
 	if err != nil {
 		return owners, err
 	}
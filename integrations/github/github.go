@@ -36,6 +36,11 @@ func MustInitGitHub(cfg *core.Configuration) *GitHub {
 	return &GitHub{cfg, client}
 }
 
+// InitGitHubWithClient uses the client as is, e.g. with another base URL.
+func InitGitHubWithClient(cfg *core.Configuration, client *github.Client) *GitHub {
+	return &GitHub{cfg, client}
+}
+
 func mustLoadGitHubToken(cfg *core.Configuration) {
 	err := core.LoadKeyringItem("GitHub User", &cfg.GitHub.Username)
 	if err != nil {
@@ -58,17 +63,26 @@ func MustSetupGitHub(cfg *core.Configuration) {
 }
 
 func (gh *GitHub) CreatePR(title, body, repoDir string) error {
+	pr, err := gh.CreatePullRequest(title, body, repoDir)
+	if err != nil {
+		return err
+	}
+	return utils.OpenURI(pr.GetHTMLURL())
+}
+
+// CreatePullRequest pushes the current branch and creates the PR. The existing PR is returned if there is one.
+func (gh *GitHub) CreatePullRequest(title, body, repoDir string) (*github.PullRequest, error) {
 	g := core.MustInitGit(repoDir)
 	err := g.Push(gh.cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	err = g.Fetch()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	branch := g.GetCurrentBranch()
-	base := "master"
+	base := g.GetBaseBranch(branch)
 	if title == "" {
 		subjects := g.LogNotInMasterSubjects()
 		if len(subjects) == 1 {
@@ -84,54 +98,130 @@ func (gh *GitHub) CreatePR(title, body, repoDir string) error {
 
 	root, err := g.GetRepositoryRootPath()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	prTemplateFile := path.Join(root, ".github", "PULL_REQUEST_TEMPLATE.md")
 	exists, err := utils.PathExists(prTemplateFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if exists {
 		content, err := ioutil.ReadFile(prTemplateFile)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		body = body + "\n\n" + string(content)
 	}
 	ctx := context.Background()
-	org := gh.cfg.GitHub.Organization
-	repo := g.GetCurrentRepositoryName()
+	org, repo, head, err := gh.resolvePRRepository(g, branch)
+	if err != nil {
+		return nil, err
+	}
 
-	request := github.NewPullRequest{Head: &branch, Base: &base, Title: &title, Body: &body}
-	pr, _, err := gh.client.PullRequests.Create(ctx, org, repo, &request)
+	request := github.NewPullRequest{Head: &head, Base: &base, Title: &title, Body: &body}
+	pr, _, createErr := gh.client.PullRequests.Create(ctx, org, repo, &request)
 
-	if err != nil {
-		prListOptions := github.PullRequestListOptions{Head: branch, Base: base}
+	if createErr != nil {
+		prListOptions := github.PullRequestListOptions{Head: head, Base: base}
 		existingPRs, _, err := gh.client.PullRequests.List(ctx, org, repo, &prListOptions)
+		if err != nil {
+			return nil, err
+		}
 		for _, existingPR := range existingPRs {
 			if strings.Contains(existingPR.GetHead().GetLabel(), branch) {
 				log.Print("Existing PR found.")
-				return utils.OpenURI(*existingPR.HTMLURL)
+				return existingPR, nil
 			}
 		}
-		return err
+		return nil, createErr
 	}
 
-	reviewers, err := gh.ListReviewers()
+	// the PR is returned even if the reviewers cannot be requested, it exists at this point.
+	reviewers, err := gh.ListReviewers(g)
 	if err != nil {
-		return err
+		log.Printf("Could not list the reviewers of PR #%v: %v", pr.GetNumber(), err)
+		return pr, nil
 	}
 	if len(reviewers) > 0 {
 		reviewersRequest := github.ReviewersRequest{Reviewers: reviewers}
-		pr, _, err = gh.client.PullRequests.RequestReviewers(ctx, org, repo, *pr.Number, reviewersRequest)
-
+		reviewed, _, err := gh.client.PullRequests.RequestReviewers(ctx, org, repo, pr.GetNumber(), reviewersRequest)
 		if err != nil {
-			return err
+			log.Printf("Could not request the reviewers of PR #%v: %v", pr.GetNumber(), err)
+			return pr, nil
+		}
+		pr = reviewed
+	}
+	return pr, nil
+}
+
+// resolvePRRepository returns the owner and name of the canonical repository and the head of the PR. When working on
+// a fork, the head is prefixed by the owner of the fork, e.g. user:branch.
+func (gh *GitHub) resolvePRRepository(g *core.Git, branch string) (owner, repo, head string, err error) {
+	upstream, err := g.GetUpstreamRemote()
+	if err != nil {
+		return "", "", "", err
+	}
+	owner, repo, head = upstream.Owner, upstream.Repository, branch
+	if owner == "" {
+		owner = gh.cfg.GitHub.Organization
+	}
+	push, err := g.GetRemote(g.GetPushRemoteName(gh.cfg))
+	if err == nil && push.Owner != "" && push.Owner != owner {
+		head = push.Owner + ":" + branch
+	}
+	return owner, repo, head, nil
+}
+
+// FindMergedPullRequest returns the merged PR of the branch, if its head is still the local branch. Squash merged
+// branches are detected this way.
+func (gh *GitHub) FindMergedPullRequest(g *core.Git, branch core.LocalBranch) (*github.PullRequest, error) {
+	prs, err := gh.listBranchPullRequests(g, branch.Name, "closed")
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range prs {
+		if pr.MergedAt != nil && pr.GetHead().GetSHA() == branch.Hash {
+			return pr, nil
 		}
+	}
+	return nil, nil
+}
 
+// GetPullRequest returns the PR of the canonical repository, the upstream remote if it exists.
+func (gh *GitHub) GetPullRequest(g *core.Git, number int) (*github.PullRequest, error) {
+	upstream, err := g.GetUpstreamRemote()
+	if err != nil {
+		return nil, err
 	}
-	return utils.OpenURI(*pr.HTMLURL)
+	owner := upstream.Owner
+	if owner == "" {
+		owner = gh.cfg.GitHub.Organization
+	}
+	pr, _, err := gh.client.PullRequests.Get(context.Background(), owner, upstream.Repository, number)
+	return pr, err
+}
+
+// FindOpenPullRequest returns the open PR of the branch, nil if there is none.
+func (gh *GitHub) FindOpenPullRequest(g *core.Git, branch string) (*github.PullRequest, error) {
+	prs, err := gh.listBranchPullRequests(g, branch, "open")
+	if err != nil || len(prs) == 0 {
+		return nil, err
+	}
+	return prs[0], nil
+}
+
+func (gh *GitHub) listBranchPullRequests(g *core.Git, branch, state string) ([]*github.PullRequest, error) {
+	owner, repo, head, err := gh.resolvePRRepository(g, branch)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(head, ":") {
+		head = owner + ":" + head
+	}
+	options := github.PullRequestListOptions{State: state, Head: head}
+	prs, _, err := gh.client.PullRequests.List(context.Background(), owner, repo, &options)
+	return prs, err
 }
 
 func (gh *GitHub) OpenPage(m *core.Manifest, p ...string) error {
@@ -156,7 +246,19 @@ func (gh *GitHub) OpenCompareCommitsPage(m *core.Manifest, commit *core.GitCommi
 }
 
 func (gh *GitHub) OpenCompareBranchPage(m *core.Manifest) error {
-	return gh.OpenPage(m, "compare", "master..."+m.Branch)
+	base := m.DefaultBranch
+	if parent := core.MustInitGit("").GetParentBranch(m.Branch); parent != "" {
+		base = parent
+	}
+	return gh.OpenPage(m, "compare", base+"..."+m.Branch)
+}
+
+// SetPullRequestBase retargets the PR, e.g. once the parent branch of a stacked branch is merged.
+func (gh *GitHub) SetPullRequestBase(pr *github.PullRequest, base string) error {
+	repo := pr.GetBase().GetRepo()
+	update := &github.PullRequest{Base: &github.PullRequestBranch{Ref: &base}}
+	_, _, err := gh.client.PullRequests.Edit(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), pr.GetNumber(), update)
+	return err
 }
 
 func (gh *GitHub) ListBranches(maxAge int) error {
@@ -191,7 +293,7 @@ func (gh *GitHub) ListBranches(maxAge int) error {
 			pullRequests[*pr.Head.SHA] = pr
 		}
 		for _, b := range branches {
-			if *b.Name == "master" {
+			if *b.Name == r.GetDefaultBranch() {
 				continue
 			}
 			b, _, err := gh.client.Repositories.GetBranch(ctx, org, *r.Name, url.PathEscape(*b.Name))
@@ -0,0 +1,84 @@
+package github
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/go-github/github"
+	"github.com/j-martin/nub/core"
+	"gopkg.in/yaml.v2"
+)
+
+type RepositoryFilter struct {
+	Archived, Forks bool
+	// the repositories must have at least one of the topics.
+	Topics []string
+	// the repositories must have a manifest marked as active.
+	Active bool
+}
+
+func (f RepositoryFilter) matches(r *github.Repository) bool {
+	if r.GetArchived() && !f.Archived || r.GetFork() && !f.Forks {
+		return false
+	}
+	if len(f.Topics) == 0 {
+		return true
+	}
+	for _, t := range r.Topics {
+		for _, expected := range f.Topics {
+			if t == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ListOrganizationRepositories lists the repositories of the organization matching the filter.
+func (gh *GitHub) ListOrganizationRepositories(filter RepositoryFilter) ([]*github.Repository, error) {
+	ctx := context.Background()
+	org := gh.cfg.GitHub.Organization
+	options := github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	var repos []*github.Repository
+	for {
+		page, response, err := gh.client.Repositories.ListByOrg(ctx, org, &options)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page {
+			if !filter.matches(r) {
+				continue
+			}
+			if filter.Active && !gh.isActive(ctx, org, r.GetName()) {
+				log.Printf("%v: no active manifest. Skipping.", r.GetName())
+				continue
+			}
+			repos = append(repos, r)
+		}
+		if response.NextPage == 0 {
+			return repos, nil
+		}
+		options.Page = response.NextPage
+	}
+}
+
+// isActive fetches the manifest of the repository, repositories without manifest are considered inactive.
+func (gh *GitHub) isActive(ctx context.Context, org, repo string) bool {
+	for _, file := range []string{".bench.yml", "manifest.yml"} {
+		content, _, _, err := gh.client.Repositories.GetContents(ctx, org, repo, file, nil)
+		if err != nil || content == nil {
+			continue
+		}
+		data, err := content.GetContent()
+		if err != nil {
+			return false
+		}
+		m := core.Manifest{}
+		if err := yaml.Unmarshal([]byte(data), &m); err != nil {
+			log.Printf("%v: failed to parse %v: %v", repo, file, err)
+			return false
+		}
+		return m.Active
+	}
+	return false
+}
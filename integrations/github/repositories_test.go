@@ -0,0 +1,22 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryFilter(t *testing.T) {
+	yes := true
+	archived := &github.Repository{Archived: &yes}
+	fork := &github.Repository{Fork: &yes}
+	tagged := &github.Repository{Topics: []string{"backend", "go"}}
+
+	assert.False(t, RepositoryFilter{}.matches(archived))
+	assert.True(t, RepositoryFilter{Archived: true}.matches(archived))
+	assert.False(t, RepositoryFilter{}.matches(fork))
+	assert.True(t, RepositoryFilter{Forks: true}.matches(fork))
+	assert.True(t, RepositoryFilter{Topics: []string{"go"}}.matches(tagged))
+	assert.False(t, RepositoryFilter{Topics: []string{"frontend"}}.matches(tagged))
+}
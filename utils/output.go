@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	TableFormat = "table"
+	JSONFormat  = "json"
+	YAMLFormat  = "yaml"
+)
+
+var OutputFormats = []string{TableFormat, JSONFormat, YAMLFormat}
+
+// PrintStructured prints the data as JSON or YAML, or the rows as a table. The first row is the header.
+func PrintStructured(format string, data interface{}, rows [][]string) error {
+	switch format {
+	case JSONFormat:
+		output, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+	case YAMLFormat:
+		output, err := yaml.Marshal(data)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(output))
+	case TableFormat, "":
+		PrintTable(rows)
+	default:
+		return errors.Errorf("unknown output format '%v', must be one of: %v", format, strings.Join(OutputFormats, ", "))
+	}
+	return nil
+}
+
+func PrintTable(rows [][]string) {
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+	table.Flush()
+}
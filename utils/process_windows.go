@@ -0,0 +1,8 @@
+//go:build windows
+
+package utils
+
+import "os/exec"
+
+// setProcessGroup is a no-op, only the command itself is killed when cancelled.
+func setProcessGroup(command *exec.Cmd) {}
@@ -3,6 +3,7 @@ package utils
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"github.com/mitchellh/go-wordwrap"
@@ -41,22 +42,45 @@ func WordWrap(text string) string {
 	return wordwrap.WrapString(text, x-10)
 }
 
+// newCommand creates the command. If the context can be cancelled, the command runs in its own process group which
+// is killed with the context, the processes it started included.
+func newCommand(ctx context.Context, cmd string, args ...string) *exec.Cmd {
+	if ctx == nil || ctx.Done() == nil {
+		return exec.Command(cmd, args...)
+	}
+	command := exec.CommandContext(ctx, cmd, args...)
+	setProcessGroup(command)
+	return command
+}
+
 func RunCmd(cmd string, args ...string) error {
-	command := exec.Command(cmd, args...)
+	return RunCmdContext(context.Background(), cmd, args...)
+}
+
+func RunCmdContext(ctx context.Context, cmd string, args ...string) error {
+	command := newCommand(ctx, cmd, args...)
 	command.Stdout = os.Stdout
 	command.Stderr = os.Stderr
 	return command.Run()
 }
 
 func RunCmdWithStdout(cmd string, args ...string) (string, error) {
-	command := exec.Command(cmd, args...)
+	return RunCmdWithStdoutContext(context.Background(), cmd, args...)
+}
+
+func RunCmdWithStdoutContext(ctx context.Context, cmd string, args ...string) (string, error) {
+	command := newCommand(ctx, cmd, args...)
 	command.Stderr = os.Stderr
 	output, err := command.Output()
 	return strings.Trim(string(output), "\n"), err
 }
 
 func RunCmdWithFullOutput(cmd string, args ...string) (string, error) {
-	command := exec.Command(cmd, args...)
+	return RunCmdWithFullOutputContext(context.Background(), cmd, args...)
+}
+
+func RunCmdWithFullOutputContext(ctx context.Context, cmd string, args ...string) (string, error) {
+	command := newCommand(ctx, cmd, args...)
 	var buf bytes.Buffer
 	command.Stderr = &buf
 	command.Stdout = &buf
@@ -64,6 +88,21 @@ func RunCmdWithFullOutput(cmd string, args ...string) (string, error) {
 	return strings.Join(args, " ") + "\n" + strings.Trim(string(buf.String()), "\n"), err
 }
 
+// RunCmdInDirWithFullOutput runs the command in the directory and returns its stdout and stderr.
+func RunCmdInDirWithFullOutput(dir, cmd string, args ...string) (string, error) {
+	return RunCmdInDirWithFullOutputContext(context.Background(), dir, cmd, args...)
+}
+
+func RunCmdInDirWithFullOutputContext(ctx context.Context, dir, cmd string, args ...string) (string, error) {
+	command := newCommand(ctx, cmd, args...)
+	command.Dir = dir
+	var buf bytes.Buffer
+	command.Stderr = &buf
+	command.Stdout = &buf
+	err := command.Run()
+	return strings.Trim(buf.String(), "\n"), err
+}
+
 func Prompt(message string) {
 	fmt.Println("\n" + message)
 	fmt.Print("Press 'Enter' to continue...")
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -179,6 +180,52 @@ func PickItem(label string, items []string) (string, error) {
 	return items[i], err
 }
 
+// PickItems lets the user toggle the items until 'Done' is picked. No item is selected initially.
+func PickItems(label string, items []string) ([]string, error) {
+	selected := make([]bool, len(items))
+	for {
+		count := 0
+		for _, s := range selected {
+			if s {
+				count++
+			}
+		}
+		choices := []string{fmt.Sprintf("Done, %v selected", count)}
+		for i, item := range items {
+			mark := "[ ] "
+			if selected[i] {
+				mark = "[x] "
+			}
+			choices = append(choices, mark+item)
+		}
+		prompt := promptui.Select{
+			Size:  20,
+			Label: label + " (toggle with enter)",
+			Items: choices,
+			Templates: &promptui.SelectTemplates{
+				Label:    "{{ . }}:",
+				Active:   "▶ {{ . }}",
+				Inactive: "  {{ . }}",
+				Selected: "▶ {{ . }}",
+			},
+		}
+		i, _, err := prompt.Run()
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			var picked []string
+			for j, item := range items {
+				if selected[j] {
+					picked = append(picked, item)
+				}
+			}
+			return picked, nil
+		}
+		selected[i-1] = !selected[i-1]
+	}
+}
+
 func Random(min, max int) int {
 	rand.Seed(time.Now().UnixNano())
 	return rand.Intn(max-min) + min